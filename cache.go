@@ -0,0 +1,236 @@
+package createrepo
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io/fs"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// ChecksumCache caches the checksums of package files, so unchanged
+// RPMs don't have to be read in full on every run. Implementations
+// must be safe for concurrent use.
+type ChecksumCache interface {
+	// Get returns the cached hex encoded digest of the given
+	// checksum type for the named file. The cached digest must
+	// only be returned if it was computed for a file with the
+	// same size, modification time and inode as fi.
+	Get(name string, fi fs.FileInfo, checksumType string) (string, bool)
+
+	// Set caches the hex encoded digest of the given checksum
+	// type for the named file described by fi.
+	Set(name string, fi fs.FileInfo, checksumType, digest string) error
+
+	// Flush persists the cache, if needed. It is called when all
+	// RPMs have been processed.
+	Flush() error
+}
+
+// cacheEntry represents a cached checksum and the state of the file
+// it was computed for.
+type cacheEntry struct {
+	Size   int64  `xml:"size,attr"`
+	MTime  int64  `xml:"mtime,attr"`
+	Inode  uint64 `xml:"inode,attr"`
+	Digest string `xml:"digest,attr"`
+}
+
+// newCacheEntry returns a cache entry for the file described by fi.
+func newCacheEntry(fi fs.FileInfo, digest string) *cacheEntry {
+	return &cacheEntry{
+		Size:   fi.Size(),
+		MTime:  fi.ModTime().UnixNano(),
+		Inode:  fileInode(fi),
+		Digest: digest,
+	}
+}
+
+// parseCacheEntry parses a cache entry in the format returned by
+// String.
+func parseCacheEntry(s string) (*cacheEntry, bool) {
+	fields := strings.Fields(s)
+	if len(fields) != 4 {
+		return nil, false
+	}
+
+	size, err1 := strconv.ParseInt(fields[0], 10, 64)
+	mtime, err2 := strconv.ParseInt(fields[1], 10, 64)
+	inode, err3 := strconv.ParseUint(fields[2], 10, 64)
+	if err1 != nil || err2 != nil || err3 != nil {
+		return nil, false
+	}
+
+	return &cacheEntry{Size: size, MTime: mtime, Inode: inode, Digest: fields[3]}, true
+}
+
+func (e *cacheEntry) String() string {
+	return fmt.Sprintf("%d %d %d %s", e.Size, e.MTime, e.Inode, e.Digest)
+}
+
+// valid returns true if the entry was computed for a file with the
+// same size, modification time and inode as fi, and the digest is
+// valid for the checksum type.
+func (e *cacheEntry) valid(fi fs.FileInfo, checksumType string) bool {
+	return e.Size == fi.Size() && e.MTime == fi.ModTime().UnixNano() &&
+		e.Inode == fileInode(fi) && validDigest(checksumType, e.Digest)
+}
+
+// noChecksumCache is a ChecksumCache caching nothing.
+type noChecksumCache struct{}
+
+// NewNoChecksumCache returns a ChecksumCache caching nothing. Every
+// RPM is read in full on every run.
+func NewNoChecksumCache() ChecksumCache {
+	return noChecksumCache{}
+}
+
+func (noChecksumCache) Get(string, fs.FileInfo, string) (string, bool) { return "", false }
+
+func (noChecksumCache) Set(string, fs.FileInfo, string, string) error { return nil }
+
+func (noChecksumCache) Flush() error { return nil }
+
+// fileChecksumCache is a ChecksumCache stored in a single file.
+type fileChecksumCache struct {
+	name    string
+	mu      sync.Mutex
+	loaded  bool
+	entries map[string]*cacheEntry
+	used    map[string]bool
+	dirty   bool
+}
+
+// fileChecksumCacheXML represents the content of the cache file.
+type fileChecksumCacheXML struct {
+	XMLName xml.Name             `xml:"checksums"`
+	Files   []*fileChecksumEntry `xml:"file"`
+}
+
+// fileChecksumEntry represents a single checksum in the cache file.
+type fileChecksumEntry struct {
+	Name string `xml:"name,attr"`
+	Type string `xml:"type,attr"`
+	cacheEntry
+}
+
+// NewFileChecksumCache returns a ChecksumCache stored in the named
+// file. It is useful when the RPMs are on a file system without
+// support for user extended attributes, or are read-only. Entries of
+// files removed or changed are dropped upon Flush.
+func NewFileChecksumCache(name string) ChecksumCache {
+	return &fileChecksumCache{name: name}
+}
+
+// load reads the cache file, unless already read. A missing or
+// unreadable cache file results in an empty cache.
+func (c *fileChecksumCache) load() {
+	if c.loaded {
+		return
+	}
+	c.loaded = true
+	c.entries = make(map[string]*cacheEntry)
+	c.used = make(map[string]bool)
+
+	content, err := os.ReadFile(c.name)
+	if err != nil {
+		return
+	}
+
+	x := &fileChecksumCacheXML{}
+	if err := xml.Unmarshal(content, x); err != nil {
+		return
+	}
+
+	for _, f := range x.Files {
+		e := f.cacheEntry
+		c.entries[f.Type+" "+f.Name] = &e
+	}
+}
+
+func (c *fileChecksumCache) Get(name string, fi fs.FileInfo, checksumType string) (string, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.load()
+
+	key := checksumType + " " + name
+	e, ok := c.entries[key]
+	if !ok || !e.valid(fi, checksumType) {
+		return "", false
+	}
+	c.used[key] = true
+
+	return e.Digest, true
+}
+
+func (c *fileChecksumCache) Set(name string, fi fs.FileInfo, checksumType, digest string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.load()
+
+	key := checksumType + " " + name
+	c.entries[key] = newCacheEntry(fi, digest)
+	c.used[key] = true
+	c.dirty = true
+
+	return nil
+}
+
+// Flush writes the cache file if any entries were added or
+// dropped. Entries not used since the cache was loaded are dropped if
+// their file no longer exists, or has changed. They are not dropped
+// just for not being used, as unchanged RPMs are not looked up in
+// update mode.
+func (c *fileChecksumCache) Flush() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.load()
+
+	for key, e := range c.entries {
+		if c.used[key] {
+			continue
+		}
+		checksumType, name, _ := strings.Cut(key, " ")
+		if fi, err := os.Stat(name); err == nil && e.valid(fi, checksumType) {
+			continue
+		}
+		delete(c.entries, key)
+		c.dirty = true
+	}
+
+	if !c.dirty {
+		return nil
+	}
+
+	x := &fileChecksumCacheXML{}
+	for key, e := range c.entries {
+		checksumType, name, _ := strings.Cut(key, " ")
+		x.Files = append(x.Files, &fileChecksumEntry{Name: name, Type: checksumType, cacheEntry: *e})
+	}
+	sortFileChecksumEntries(x.Files)
+
+	b, err := xmlencode(x)
+	if err != nil {
+		return err
+	}
+
+	if _, err := writeFile(c.name, b); err != nil {
+		return err
+	}
+	c.dirty = false
+
+	return nil
+}
+
+// sortFileChecksumEntries sorts the entries by name and type.
+func sortFileChecksumEntries(files []*fileChecksumEntry) {
+	sort.Slice(files, func(i, j int) bool {
+		if files[i].Name != files[j].Name {
+			return files[i].Name < files[j].Name
+		}
+		return files[i].Type < files[j].Type
+	})
+}
@@ -0,0 +1,120 @@
+package createrepo
+
+import (
+	"encoding/xml"
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+// readChecksumCache returns the names and types of the entries in the
+// checksum cache file.
+func readChecksumCache(t *testing.T, name string) string {
+	t.Helper()
+
+	content, err := os.ReadFile(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	x := &fileChecksumCacheXML{}
+	if err := xml.Unmarshal(content, x); err != nil {
+		t.Fatal(err)
+	}
+
+	var ls []string
+	for _, f := range x.Files {
+		ls = append(ls, f.Type+" "+f.Name[strings.LastIndex(f.Name, "/")+1:])
+	}
+
+	return strings.Join(ls, ", ")
+}
+
+func TestFileChecksumCache(t *testing.T) {
+	dir := t.TempDir()
+	name := dir + "/a.rpm"
+	if err := os.WriteFile(name, []byte("a"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	fi, err := os.Stat(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	digest := strings.Repeat("ab", 32)
+	c := NewFileChecksumCache(dir + "/cache.xml")
+	if err := c.Set(name, fi, "sha256", digest); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.Flush(); err != nil {
+		t.Fatal(err)
+	}
+
+	c = NewFileChecksumCache(dir + "/cache.xml")
+	if got, ok := c.Get(name, fi, "sha256"); !ok || got != digest {
+		t.Fatalf("got %q, %t, expected %q, true", got, ok, digest)
+	}
+	if _, ok := c.Get(name, fi, "sha1"); ok {
+		t.Fatalf("got a sha1 digest, expected none")
+	}
+
+	// Changed file
+	mtime := fi.ModTime().Add(time.Second)
+	if err := os.Chtimes(name, mtime, mtime); err != nil {
+		t.Fatal(err)
+	}
+	changed, err := os.Stat(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c = NewFileChecksumCache(dir + "/cache.xml")
+	if _, ok := c.Get(name, changed, "sha256"); ok {
+		t.Fatalf("got a digest of a changed file, expected none")
+	}
+	if err := c.Flush(); err != nil {
+		t.Fatal(err)
+	}
+	if got := readChecksumCache(t, dir+"/cache.xml"); got != "" {
+		t.Fatalf("got entries %q, expected none", got)
+	}
+}
+
+func TestFileChecksumCacheUpdate(t *testing.T) {
+	dir := t.TempDir()
+	cacheFile := dir + "/" + checksumsXML
+	writeTestRPM(t, dir+"/a.rpm", &testRPM{Name: "a", Version: "1.0", Release: "1", Arch: "noarch"})
+	writeTestRPM(t, dir+"/b.rpm", &testRPM{Name: "b", Version: "1.0", Release: "1", Arch: "noarch"})
+
+	tests := []struct {
+		// Change is done before creating the repository
+		Change func()
+		Expect string
+	}{
+		{func() {}, "sha256 a.rpm, sha256 b.rpm"},
+		{func() {}, "sha256 a.rpm, sha256 b.rpm"},
+		{func() {
+			writeTestRPM(t, dir+"/c.rpm", &testRPM{Name: "c", Version: "1.0", Release: "1", Arch: "noarch"})
+		}, "sha256 a.rpm, sha256 b.rpm, sha256 c.rpm"},
+		{func() {
+			if err := os.Remove(dir + "/b.rpm"); err != nil {
+				t.Fatal(err)
+			}
+		}, "sha256 a.rpm, sha256 c.rpm"},
+	}
+
+	for i, test := range tests {
+		test.Change()
+
+		r, err := NewRepo(dir, &Config{Update: true, ChecksumCacheType: "file"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := r.Create(); err != nil {
+			t.Fatal(err)
+		}
+
+		if got := readChecksumCache(t, cacheFile); got != test.Expect {
+			t.Fatalf("test %d: got %q, expected %q", i, got, test.Expect)
+		}
+	}
+}
@@ -0,0 +1,280 @@
+// Package main implements the CLI of createrepo - a program used for
+// creating RPM repositories on a local file system.
+//
+// Usage:
+//
+//	createrepo [flags] dir...
+//	createrepo rollback [flags] dir revision
+//	createrepo gc [flags] dir...
+//	createrepo history [flags] dir
+package main
+
+import (
+	"flag"
+	"fmt"
+	"github.com/stianwa/createrepo"
+	"os"
+	"strconv"
+	"time"
+)
+
+func main() {
+	if len(os.Args) > 1 {
+		switch os.Args[1] {
+		case "rollback":
+			rollback(os.Args[2:])
+			return
+		case "gc":
+			collectGarbage(os.Args[2:])
+			return
+		case "history":
+			history(os.Args[2:])
+			return
+		}
+	}
+
+	create(os.Args[1:])
+}
+
+// create creates or updates the repositories named in args.
+func create(args []string) {
+	var opt struct {
+		Group   string
+		Message string
+		Output  string
+		Verbose bool
+		Expunge int64
+	}
+
+	fs := flag.NewFlagSet("createrepo", flag.ExitOnError)
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "usage: createrepo [flags] dir...\n       createrepo rollback [flags] dir revision\n       createrepo gc [flags] dir...\n       createrepo history [flags] dir\n")
+		fs.PrintDefaults()
+	}
+	fs.StringVar(&opt.Message, "m", "", "Message recorded in history")
+	fs.StringVar(&opt.Group, "g", "", "Comps group `file`")
+	fs.StringVar(&opt.Output, "o", "", "Output `dir` for repodata, if not the repo dir")
+	fs.BoolVar(&opt.Verbose, "v", false, "Verbose output")
+	fs.Int64Var(&opt.Expunge, "e", 172800, "Expunge dead meta data older than `n` seconds.")
+	fs.Parse(args)
+
+	if fs.NArg() == 0 {
+		fs.Usage()
+		os.Exit(2)
+	}
+
+	set := make(map[string]bool)
+	fs.Visit(func(f *flag.Flag) { set[f.Name] = true })
+
+	for _, arg := range fs.Args() {
+		output := opt.Output
+		if output == "" {
+			output = arg
+		}
+
+		// The saved config is kept, but for the settings given
+		config, err := createrepo.ReadConfig(output)
+		if err != nil {
+			abortProgram("config: %v", err)
+		}
+		if config == nil {
+			config = &createrepo.Config{WriteConfig: true, CompsFile: opt.Group, ExpungeOldMetadata: opt.Expunge}
+		} else {
+			if set["g"] {
+				config.CompsFile = opt.Group
+				config.WriteConfig = true
+			}
+			if set["e"] {
+				config.ExpungeOldMetadata = opt.Expunge
+				config.WriteConfig = true
+			}
+		}
+		config.Message = opt.Message
+
+		r, err := createrepo.NewRepoWithOutputDir(arg, output, config)
+		if err != nil {
+			abortProgram("new repo: %v", err)
+		}
+
+		summary, err := r.Create()
+		if err != nil {
+			abortProgram("create repo: %v", err)
+		}
+
+		fmt.Println(summary)
+		if opt.Verbose {
+			for _, name := range summary.Pruned {
+				fmt.Printf("pruned: %s\n", name)
+			}
+		}
+	}
+}
+
+// rollback rolls the repository back to a previous revision.
+func rollback(args []string) {
+	var opt struct {
+		Message string
+		Output  string
+	}
+
+	fs := flag.NewFlagSet("createrepo rollback", flag.ExitOnError)
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "usage: createrepo rollback [flags] dir revision\n")
+		fs.PrintDefaults()
+	}
+	fs.StringVar(&opt.Message, "m", "", "Message recorded in history")
+	fs.StringVar(&opt.Output, "o", "", "Output `dir` for repodata, if not the repo dir")
+	fs.Parse(args)
+
+	if fs.NArg() != 2 {
+		fs.Usage()
+		os.Exit(2)
+	}
+
+	dir := fs.Arg(0)
+	revision, err := strconv.ParseInt(fs.Arg(1), 10, 64)
+	if err != nil {
+		abortProgram("revision: %v", err)
+	}
+
+	output := opt.Output
+	if output == "" {
+		output = dir
+	}
+
+	config, err := createrepo.ReadConfig(output)
+	if err != nil {
+		abortProgram("config: %v", err)
+	}
+	if config == nil {
+		config = &createrepo.Config{WriteConfig: true}
+	}
+	config.Message = opt.Message
+
+	r, err := createrepo.NewRepoWithOutputDir(dir, output, config)
+	if err != nil {
+		abortProgram("new repo: %v", err)
+	}
+
+	if err := r.Rollback(revision); err != nil {
+		abortProgram("rollback: %v", err)
+	}
+
+	fmt.Printf("repo:%s rollback:%d\n", dir, revision)
+}
+
+// collectGarbage removes unreferenced files in repodata of the
+// repositories named in args.
+func collectGarbage(args []string) {
+	var opt struct {
+		Output string
+		DryRun bool
+	}
+
+	fs := flag.NewFlagSet("createrepo gc", flag.ExitOnError)
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "usage: createrepo gc [flags] dir...\n")
+		fs.PrintDefaults()
+	}
+	fs.StringVar(&opt.Output, "o", "", "Output `dir` for repodata, if not the repo dir")
+	fs.BoolVar(&opt.DryRun, "n", false, "Dry run, only list the files to be removed")
+	fs.Parse(args)
+
+	if fs.NArg() == 0 {
+		fs.Usage()
+		os.Exit(2)
+	}
+
+	for _, dir := range fs.Args() {
+		output := opt.Output
+		if output == "" {
+			output = dir
+		}
+
+		r, err := createrepo.NewRepoWithOutputDir(dir, output, nil)
+		if err != nil {
+			abortProgram("new repo: %v", err)
+		}
+
+		garbage, err := r.CollectGarbage(opt.DryRun)
+		for _, name := range garbage {
+			fmt.Println(output + "/" + name)
+		}
+		if err != nil {
+			abortProgram("gc: %v", err)
+		}
+	}
+}
+
+// history prints the revisions in the history of a repository.
+func history(args []string) {
+	var opt struct {
+		Output  string
+		Verbose bool
+	}
+
+	fs := flag.NewFlagSet("createrepo history", flag.ExitOnError)
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "usage: createrepo history [flags] dir\n")
+		fs.PrintDefaults()
+	}
+	fs.StringVar(&opt.Output, "o", "", "Output `dir` for repodata, if not the repo dir")
+	fs.BoolVar(&opt.Verbose, "v", false, "Verbose output, list the changed packages")
+	fs.Parse(args)
+
+	if fs.NArg() != 1 {
+		fs.Usage()
+		os.Exit(2)
+	}
+
+	dir := fs.Arg(0)
+	output := opt.Output
+	if output == "" {
+		output = dir
+	}
+
+	r, err := createrepo.NewRepoWithOutputDir(dir, output, nil)
+	if err != nil {
+		abortProgram("new repo: %v", err)
+	}
+
+	revisions, err := r.History()
+	if err != nil {
+		abortProgram("history: %v", err)
+	}
+
+	for _, rev := range revisions {
+		fmt.Printf("revision:%d time:%s packages:%d added:%d removed:%d updated:%d",
+			rev.Revision, time.Unix(rev.Revision, 0).Format(time.RFC3339),
+			rev.Packages, len(rev.Added), len(rev.Removed), len(rev.Updated))
+		if rev.User != "" || rev.Host != "" {
+			fmt.Printf(" by:%s@%s", rev.User, rev.Host)
+		}
+		if rev.Rollback != 0 {
+			fmt.Printf(" rollback:%d", rev.Rollback)
+		}
+		if rev.Current {
+			fmt.Print(" current")
+		}
+		fmt.Println()
+		if rev.Message != "" {
+			fmt.Printf("  %s\n", rev.Message)
+		}
+		if opt.Verbose {
+			for _, nevra := range rev.Added {
+				fmt.Printf("  + %s\n", nevra)
+			}
+			for _, nevra := range rev.Removed {
+				fmt.Printf("  - %s\n", nevra)
+			}
+			for _, u := range rev.Updated {
+				fmt.Printf("  ~ %s -> %s\n", u.From, u.To)
+			}
+		}
+	}
+}
+
+func abortProgram(format string, a ...any) {
+	fmt.Fprintf(os.Stderr, format+"\n", a...)
+	os.Exit(1)
+}
@@ -0,0 +1,86 @@
+// Package main implements repodiff - a program listing the package
+// differences between two RPM repositories, or two revisions of one.
+//
+// Usage:
+//
+//	repodiff [flags] old new
+//
+// Each of old and new is a repository directory, a repomd.xml file,
+// or a repository directory and a revision in its history as
+// dir@revision.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"github.com/stianwa/createrepo"
+	"os"
+	"strconv"
+	"strings"
+)
+
+func main() {
+	var opt struct {
+		JSON bool
+	}
+
+	flag.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(), "usage: repodiff [flags] old new\n\nold and new are repo dirs, repomd.xml files or dir@revision\n\n")
+		flag.PrintDefaults()
+	}
+	flag.BoolVar(&opt.JSON, "json", false, "JSON output")
+	flag.Parse()
+
+	if flag.NArg() != 2 {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	old, err := openRepo(flag.Arg(0))
+	if err != nil {
+		abortProgram("%s: %v", flag.Arg(0), err)
+	}
+
+	cur, err := openRepo(flag.Arg(1))
+	if err != nil {
+		abortProgram("%s: %v", flag.Arg(1), err)
+	}
+
+	diff := createrepo.Diff(old, cur)
+
+	if opt.JSON {
+		b, err := diff.JSON()
+		if err != nil {
+			abortProgram("json: %v", err)
+		}
+		os.Stdout.Write(b)
+		return
+	}
+
+	fmt.Print(diff)
+}
+
+// openRepo opens the repository, repomd.xml file or revision named by
+// spec.
+func openRepo(spec string) (*createrepo.Repository, error) {
+	if n := strings.LastIndex(spec, "@"); n > 0 {
+		if rev, err := strconv.ParseInt(spec[n+1:], 10, 64); err == nil {
+			return createrepo.OpenRevision(spec[:n], rev)
+		}
+	}
+
+	fi, err := os.Stat(spec)
+	if err != nil {
+		return nil, err
+	}
+	if !fi.IsDir() {
+		return createrepo.OpenRepoMD(spec)
+	}
+
+	return createrepo.OpenRepo(spec)
+}
+
+func abortProgram(format string, a ...any) {
+	fmt.Fprintf(os.Stderr, format+"\n", a...)
+	os.Exit(1)
+}
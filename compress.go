@@ -4,55 +4,118 @@ import (
 	"bytes"
 	"compress/gzip"
 	"fmt"
+	"github.com/klauspost/compress/zstd"
 	"github.com/ulikunitz/xz"
+	"io"
+	"strings"
 )
 
-func compress(data []byte, algo string) (compressed []byte, checksum *checksum, suffix string, err error) {
+// xzDictCap maps the xz compression presets 0-9 to dictionary
+// capacities, as done by the xz utility.
+var xzDictCap = []int{
+	256 << 10, 1 << 20, 2 << 20, 4 << 20, 4 << 20,
+	8 << 20, 8 << 20, 16 << 20, 32 << 20, 64 << 20,
+}
+
+// checkCompression returns an error if the algorithm is not supported
+// or the level is out of range for the algorithm. Level 0 selects
+// the default level of the algorithm.
+func checkCompression(algo string, level int) error {
+	var maxLevel int
 	switch algo {
-	case "xz":
-		compressed, err = xzCompress(data)
-		suffix = ".xz"
-	case "gz":
-		compressed, err = gzCompress(data)
-		suffix = ".gz"
+	case "xz", "gz":
+		maxLevel = 9
+	case "zst":
+		maxLevel = 22
 	default:
-		return nil, nil, "", fmt.Errorf("unsupported compress algo: %s", algo)
+		return fmt.Errorf("unsupported compression algorithm: %s", algo)
 	}
 
-	checksum = getChecksumOfBytes(compressed)
-	return compressed, checksum, suffix, err
-}
-
-func xzCompress(data []byte) ([]byte, error) {
-	var buf bytes.Buffer
-	z, err := xz.NewWriter(&buf)
-	if err != nil {
-		return nil, err
+	if level < 0 || level > maxLevel {
+		return fmt.Errorf("compression level %d out of range 0-%d for %s", level, maxLevel, algo)
 	}
 
-	if _, err := z.Write(data); err != nil {
-		return nil, err
+	return nil
+}
+
+// newCompressor returns a writer compressing to w with the named
+// algorithm and level, and the file name suffix of the
+// algorithm. Level 0 selects the default level. The returned writer
+// must be closed to flush the compressed stream.
+func newCompressor(w io.Writer, algo string, level int) (io.WriteCloser, string, error) {
+	if err := checkCompression(algo, level); err != nil {
+		return nil, "", err
 	}
 
-	if err := z.Close(); err != nil {
-		return nil, err
+	switch algo {
+	case "xz":
+		c := xz.WriterConfig{}
+		if level > 0 {
+			c.DictCap = xzDictCap[level]
+		}
+		z, err := c.NewWriter(w)
+		if err != nil {
+			return nil, "", err
+		}
+		return z, ".xz", nil
+	case "gz":
+		if level == 0 {
+			level = gzip.DefaultCompression
+		}
+		z, err := gzip.NewWriterLevel(w, level)
+		if err != nil {
+			return nil, "", err
+		}
+		return z, ".gz", nil
+	case "zst":
+		var opts []zstd.EOption
+		if level > 0 {
+			opts = append(opts, zstd.WithEncoderLevel(zstd.EncoderLevelFromZstd(level)))
+		}
+		z, err := zstd.NewWriter(w, opts...)
+		if err != nil {
+			return nil, "", err
+		}
+		return z, ".zst", nil
 	}
 
-	return buf.Bytes(), nil
+	return nil, "", fmt.Errorf("unsupported compress algo: %s", algo)
 }
 
-func gzCompress(data []byte) ([]byte, error) {
-	var buf bytes.Buffer
-	z := gzip.NewWriter(&buf)
-
-	_, err := z.Write(data)
-	if err != nil {
-		return nil, err
-	}
+// compressSuffix returns the file name suffix of the compression
+// algorithm, including zck.
+func compressSuffix(algo string) string {
+	return "." + algo
+}
 
-	if err := z.Close(); err != nil {
-		return nil, err
+// decompress decompresses data using the algorithm given by the file
+// name suffix. Data with an unknown suffix is returned as is.
+func decompress(data []byte, name string) ([]byte, error) {
+	var r io.Reader
+	switch {
+	case strings.HasSuffix(name, ".xz"):
+		z, err := xz.NewReader(bytes.NewReader(data))
+		if err != nil {
+			return nil, err
+		}
+		r = z
+	case strings.HasSuffix(name, ".gz"):
+		z, err := gzip.NewReader(bytes.NewReader(data))
+		if err != nil {
+			return nil, err
+		}
+		defer z.Close()
+		r = z
+	case strings.HasSuffix(name, ".zst"):
+		z, err := zstd.NewReader(bytes.NewReader(data))
+		if err != nil {
+			return nil, err
+		}
+		defer z.Close()
+		r = z
+	default:
+		return data, nil
 	}
 
-	return buf.Bytes(), nil
+	return io.ReadAll(r)
 }
@@ -107,43 +107,16 @@ func readComps(name string) (*comps, error) {
 		return nil, err
 	}
 
-	compsXML, err := comps.XML()
-	if err != nil {
-		return nil, err
-	}
-	comps.OpenSize = uint64(len(compsXML))
-	comps.OpenChecksum = getChecksumOfBytes(compsXML)
-
 	return comps, nil
 }
 
-// writeData returns the metadata and file content of the comps section
-func (c *comps) writeData(baseDir, compressAlgo string) (*data, error) {
-	x, err := c.XML()
-	if err != nil {
-		return nil, err
-	}
-	compressed, checksum, suffix, err := compress(x, compressAlgo)
-	if err != nil {
-		return nil, err
-	}
-
-	size := uint64(len(compressed))
-	href := repoDataDir + "/" + checksum.Data + "-comps.xml" + suffix
-	modTime, err := writeFile(baseDir+"/"+href, compressed)
-	if err != nil {
-		return nil, err
-	}
-
-	data := &data{
-		Type:         "group",
-		Checksum:     checksum,
-		OpenChecksum: c.OpenChecksum,
-		Location:     &location{Href: href},
-		Size:         &size,
-		OpenSize:     &c.OpenSize,
-		Timestamp:    &modTime,
-	}
+// stage encodes the comps into compressed temporary files, one per
+// compression algorithm
+func (c *comps) stage(baseDir string, config *Config) ([]*stagedFile, error) {
+	return stageFiles(baseDir, "group", "comps.xml", config.compressAlgos(), config.CompressLevel, config.ChecksumType, c.encode)
+}
 
-	return data, nil
+// encode encodes the comps as a single chunk
+func (c *comps) encode(w chunkWriter) error {
+	return encodeXML(w, c)
 }
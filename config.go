@@ -11,22 +11,194 @@ import (
 type Config struct {
 	// CompressAlgo specifies which compression algorithm to be
 	// used for compressing the meta files. Supported algorithms
-	// are: xz (default) and gz.
+	// are: xz (default), gz and zst.
 	CompressAlgo string `yaml:"compressAlgo,omitempty"`
 
+	// CompressVariants specifies additional compression
+	// algorithms. Each meta file is also written compressed with
+	// these, and published in repomd.xml as the data type
+	// suffixed with _<algo>, e.g. primary_gz and group_gz.
+	CompressVariants []string `yaml:"compressVariants,omitempty"`
+
+	// Zchunk also writes primary, filelists and other as zchunk
+	// files with one chunk per package, published in repomd.xml as
+	// primary_zck, filelists_zck and other_zck. Clients can then
+	// download only the chunks that changed.
+	Zchunk bool `yaml:"zchunk,omitempty"`
+
+	// Database also writes the classic createrepo sqlite
+	// databases, published in repomd.xml as primary_db,
+	// filelists_db and other_db, for clients like yum on EL7.
+	Database bool `yaml:"database,omitempty"`
+
+	// CompressLevel specifies the compression level. The range is
+	// 1-9 for xz and gz, and 1-22 for zst. Zero (default) uses
+	// the default level of the algorithm.
+	CompressLevel int `yaml:"compressLevel,omitempty"`
+
+	// ChecksumType specifies the checksum algorithm used for
+	// package checksums (pkgid), meta data checksums and the
+	// checksum prefix of the meta data file names. Supported
+	// types are: sha1, sha224, sha256 (default), sha384 and
+	// sha512.
+	ChecksumType string `yaml:"checksumType,omitempty"`
+
+	// ChecksumCacheType specifies where package checksums are
+	// cached between runs: xattr (default) in extended attributes
+	// of the RPMs, falling back to file for RPMs where attributes
+	// can't be written; file in repodata/.checksums.xml; none
+	// disables caching.
+	ChecksumCacheType string `yaml:"checksumCache,omitempty"`
+
+	// ChecksumCache is a custom checksum cache. If set, it is
+	// used instead of ChecksumCacheType.
+	ChecksumCache ChecksumCache `yaml:"-"`
+
+	// BaseURL specifies the URL the RPMs are served from, if not
+	// the same as for the repodata. It is written as xml:base of
+	// the package locations in primary.
+	BaseURL string `yaml:"baseURL,omitempty"`
+
+	// PackageBaseURL returns the base URL of the named RPM, with
+	// the path relative to the repository directory. If it
+	// returns an empty string, BaseURL is used.
+	PackageBaseURL func(name string) string `yaml:"-"`
+
 	// CompsFile specifies a path to a comps group (yumgroup)
 	// file, if used.
 	CompsFile string `yaml:"compsFile,omitempty"`
 
+	// ChangelogLimit specifies the maximum number of changelog
+	// entries per package to include in other.xml. Only the
+	// newest entries are kept. Zero (default) includes all
+	// entries.
+	ChangelogLimit int `yaml:"changelogLimit,omitempty"`
+
 	// ExpungeOldMetadata specifies the time in seconds when old
 	// metadata should be deleted from disk and history. The
 	// default is 172800 (48 hours).
 	ExpungeOldMetadata int64 `yaml:"expungeOldMetadata"`
 
+	// RetainRevisions specifies the number of old revisions to
+	// keep in history regardless of ExpungeOldMetadata. The
+	// default (zero) keeps only the revisions not yet expired.
+	RetainRevisions int `yaml:"retainRevisions,omitempty"`
+
+	// MaxRevisions specifies the maximum number of old revisions
+	// to keep in history, even if not yet expired by
+	// ExpungeOldMetadata. The newest are kept. The default (zero)
+	// has no limit.
+	MaxRevisions int `yaml:"maxRevisions,omitempty"`
+
+	// Workers specifies the number of RPMs to parse
+	// concurrently. The default (zero) is GOMAXPROCS.
+	Workers int `yaml:"workers,omitempty"`
+
+	// Include specifies doublestar glob patterns, like
+	// "x86_64/**/*.rpm", matched against the path of each RPM
+	// relative to the repository directory. If set, only
+	// matching RPMs are included.
+	Include []string `yaml:"include,omitempty"`
+
+	// Exclude specifies doublestar glob patterns, like
+	// "**/debug" or "**/*-debuginfo-*.rpm", matched against paths
+	// relative to the repository directory. Matching RPMs are
+	// excluded, and matching directories are not descended into.
+	Exclude []string `yaml:"exclude,omitempty"`
+
+	// FollowSymlinks follows symbolic links to directories when
+	// looking for RPMs. The location of an RPM is the path of the
+	// link, not the path of its target. Links forming a loop are
+	// skipped.
+	FollowSymlinks bool `yaml:"followSymlinks,omitempty"`
+
+	// AllowExternalSymlinks allows followed symbolic links with
+	// targets outside of the repository directory. By default,
+	// such links are skipped.
+	AllowExternalSymlinks bool `yaml:"allowExternalSymlinks,omitempty"`
+
+	// GarbageCollect removes files in repodata not referenced by
+	// repomd.xml or history after creating the repository, see
+	// Repo.CollectGarbage.
+	GarbageCollect bool `yaml:"garbageCollect,omitempty"`
+
+	// GarbageGracePeriod specifies the minimum age in seconds of
+	// files removed by garbage collection. The default (zero) is
+	// 3600 (one hour).
+	GarbageGracePeriod int64 `yaml:"garbageGracePeriod,omitempty"`
+
+	// KeepVersions specifies the number of versions of each
+	// package name and arch to keep in the meta data. Only the
+	// newest versions are kept. Zero (default) keeps all
+	// versions.
+	KeepVersions int `yaml:"keepVersions,omitempty"`
+
+	// PruneAction specifies what to do with the RPMs of the
+	// versions not kept: delete removes them, move moves them to
+	// PruneDir. The default (empty) leaves them in place, only
+	// leaving them out of the meta data.
+	PruneAction string `yaml:"pruneAction,omitempty"`
+
+	// PruneDir specifies the directory RPMs are moved to by the
+	// move PruneAction, keeping their path relative to the
+	// repository directory. If it is within the repository
+	// directory, it is not searched for RPMs.
+	PruneDir string `yaml:"pruneDir,omitempty"`
+
+	// Update reuses the package metadata in the current
+	// repomd.xml for RPMs with unchanged location, size and
+	// modification time. Only new or changed RPMs are parsed.
+	// Nothing is reused if ChecksumType or ChangelogLimit changed
+	// since the current repomd.xml was made.
+	Update bool `yaml:"update,omitempty"`
+
+	// Rehash computes the checksum of every RPM, ignoring the
+	// checksums cached in extended attributes and, in update
+	// mode, the current meta data.
+	Rehash bool `yaml:"-"`
+
+	// Message is recorded in history with the revision published
+	// by Create, e.g. to tell why.
+	Message string `yaml:"-"`
+
 	// WriteConfig writes this Config to disk.
 	WriteConfig bool `yaml:"-"`
 }
 
+// compressAlgos returns the canonical compression algorithm followed
+// by the variants.
+func (c *Config) compressAlgos() []string {
+	return append([]string{c.CompressAlgo}, c.CompressVariants...)
+}
+
+// chunkedCompressAlgos returns the compression algorithms for meta
+// files that can be split into chunks; compressAlgos followed by zck
+// if Zchunk is set.
+func (c *Config) chunkedCompressAlgos() []string {
+	if c.Zchunk {
+		return append(c.compressAlgos(), "zck")
+	}
+	return c.compressAlgos()
+}
+
+// fingerprint returns a fingerprint of the settings affecting the
+// metadata of each package. In update mode, package metadata is only
+// reused if made with the same fingerprint.
+func (c *Config) fingerprint() string {
+	return fmt.Sprintf("checksumType=%s changelogLimit=%d", c.ChecksumType, c.ChangelogLimit)
+}
+
+// ReadConfig returns the configuration written by WriteConfig to the
+// output directory dir of a repository, or nil if there is none.
+func ReadConfig(dir string) (*Config, error) {
+	c, err := readConfig(dir)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+
+	return c, err
+}
+
 // readConfig reads a configuration from file. It is ok if the file
 // does not exists. In that case both the Config and error will be
 // returned as nil.
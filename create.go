@@ -3,33 +3,61 @@ package createrepo
 import (
 	"fmt"
 	"os"
+	"strings"
 )
 
 // Create creates or updates the epository.
 func (r *Repo) Create() (*Summary, error) {
-	if fi, err := os.Stat(r.baseDir + "/" + repoDataDir); err == nil {
+	ls, err := getRPMFiles(r.baseDir, r.config)
+	if err != nil {
+		return nil, fmt.Errorf("getRPMFileNames: %v", err)
+	}
+
+	return r.create(ls)
+}
+
+// CreateFromList creates or updates the repository with the listed
+// RPMs only, rather than all RPMs found in the repository
+// directory. The paths are either absolute or relative to the
+// repository directory, and must be within the repository
+// directory, also when resolving symbolic links unless
+// AllowExternalSymlinks is set. Include and Exclude in Config do not
+// apply.
+func (r *Repo) CreateFromList(names []string) (*Summary, error) {
+	ls, err := r.packageList(names)
+	if err != nil {
+		return nil, err
+	}
+
+	return r.create(ls)
+}
+
+// create creates or updates the repository with the named RPMs.
+func (r *Repo) create(ls []string) (*Summary, error) {
+	if fi, err := os.Stat(r.outputDir + "/" + repoDataDir); err == nil {
 		if !fi.IsDir() {
-			return nil, fmt.Errorf("%q exists, but is not a directory", r.baseDir+"/"+repoDataDir)
+			return nil, fmt.Errorf("%q exists, but is not a directory", r.outputDir+"/"+repoDataDir)
 		}
 	} else if err != nil {
 		if !os.IsNotExist(err) {
 			return nil, err
 		}
-		if err := os.Mkdir(r.baseDir+"/"+repoDataDir, 0777); err != nil {
+		if err := os.Mkdir(r.outputDir+"/"+repoDataDir, 0777); err != nil {
 			return nil, err
 		}
 	}
 
 	if r.config.WriteConfig {
-		if err := r.config.write(r.baseDir); err != nil {
+		if err := r.config.write(r.outputDir); err != nil {
 			return nil, err
 		}
 	}
 
-	repoData, err := r.getData()
+	repoData, err := r.getData(ls)
 	if err != nil {
 		return nil, fmt.Errorf("rpm meta: %v", err)
 	}
+	defer repoData.discard()
 
 	oldRepoMD, err := r.readRepoMD()
 	if err != nil {
@@ -38,75 +66,124 @@ func (r *Repo) Create() (*Summary, error) {
 
 	summary := &Summary{Dir: r.baseDir, RPMs: len(repoData.primary.Packages)}
 
-	hist, err := readHistory(r.baseDir)
+	hist, err := readHistory(r.outputDir)
 	if err != nil {
 		return nil, err
 	}
 	if hist == nil {
-		hist = newHistory(r.baseDir)
+		hist = newHistory(r.outputDir)
 	}
 
 	// If not the same data content, create new
 	if !r.sameDataContent(oldRepoMD, repoData) {
-		repomd, err := repoData.writeData(r.baseDir, r.config.CompressAlgo)
+		oldPackages := readPackages(oldRepoMD)
+
+		if err := repoData.stage(r.outputDir, r.config); err != nil {
+			return nil, fmt.Errorf("stage meta: %v", err)
+		}
+
+		repomd, err := repoData.writeData(r.outputDir)
 		if err != nil {
 			return nil, fmt.Errorf("write meta: %v", err)
 		}
+		repomd.Revision = hist.nextRevision(repomd.Revision)
+		rev, err := hist.Append(repomd)
+		if err != nil {
+			return nil, err
+		}
 
 		if err := repomd.Write(); err != nil {
 			return nil, err
 		}
 		summary.Updated = true
 
-		hist.Append(repomd)
+		rev.describe(repoData.primary.Packages, oldPackages, r.config)
+		if err := hist.write(); err != nil {
+			return nil, err
+		}
+	} else if rev := hist.find(oldRepoMD.Revision); rev != nil && rev.Settings != r.config.fingerprint() {
+		// The same content is made with the current settings
+		rev.Settings = r.config.fingerprint()
 		if err := hist.write(); err != nil {
 			return nil, err
 		}
 	}
 
-	expunged, err := hist.Clean(r.config.ExpungeOldMetadata)
+	for _, name := range repoData.pruned {
+		summary.Pruned = append(summary.Pruned, strings.TrimPrefix(name, "/"))
+	}
+	if err := r.prune(repoData.pruned); err != nil {
+		return summary, fmt.Errorf("prune: %v", err)
+	}
 
+	kept, expunged, err := hist.Clean(r.config.ExpungeOldMetadata, r.config.RetainRevisions, r.config.MaxRevisions)
+	summary.Expunged = len(expunged)
+	summary.ExpungedRevisions = expunged
+	summary.KeptRevisions = kept
 	if err != nil {
 		return summary, err
 	}
-	summary.Expunged = expunged
+
+	if r.config.GarbageCollect {
+		garbage, err := r.CollectGarbage(false)
+		summary.Garbage = garbage
+		if err != nil {
+			return summary, fmt.Errorf("garbage collect: %v", err)
+		}
+	}
 
 	return summary, nil
 }
 
+// sameDataContent returns true if the old repoMD has exactly the data
+// types the fresh data set is published as, with the same content and
+// compression, and all the files exist. The databases are made from
+// the XML files, and are the same if these are.
 func (r *Repo) sameDataContent(old *repoMD, fresh *dataSet) bool {
 	if old == nil || fresh == nil {
 		return false
 	}
 
-	var primary, fileLists, comps *data
+	oldData := make(map[string]*data)
 	for _, d := range old.Data {
-		switch d.Type {
-		case "primary":
-			primary = d
-		case "filelists":
-			fileLists = d
-		case "group":
-			comps = d
-		}
+		oldData[d.Type] = d
 	}
 
-	if fresh.primary == nil || primary == nil || fresh.fileLists == nil || fileLists == nil {
-		return false
+	n := 0
+	same := func(dataType string, algos []string, openChecksum *checksum) bool {
+		for i, algo := range algos {
+			d, ok := oldData[variantType(dataType, i, algo)]
+			if !ok || d.Location == nil || !strings.HasSuffix(d.Location.Href, compressSuffix(algo)) ||
+				!d.sameChecksumAndExists(openChecksum, r.outputDir) {
+				return false
+			}
+			n++
+		}
+		return true
 	}
 
-	if !(comps == nil && fresh.comps == nil || comps != nil && fresh.comps != nil) {
+	if !same("primary", r.config.chunkedCompressAlgos(), fresh.primary.OpenChecksum) ||
+		!same("filelists", r.config.chunkedCompressAlgos(), fresh.fileLists.OpenChecksum) ||
+		!same("other", r.config.chunkedCompressAlgos(), fresh.other.OpenChecksum) {
 		return false
 	}
 
-	if !(primary.sameChecksumAndExists(fresh.primary.OpenChecksum, r.baseDir) &&
-		fileLists.sameChecksumAndExists(fresh.fileLists.OpenChecksum, r.baseDir)) {
+	if fresh.comps != nil && !same("group", r.config.compressAlgos(), fresh.comps.OpenChecksum) {
 		return false
 	}
 
-	if fresh.comps != nil && !comps.sameChecksumAndExists(fresh.comps.OpenChecksum, r.baseDir) {
-		return false
+	if r.config.Database {
+		for _, t := range databaseTypes {
+			d, ok := oldData[t]
+			if !ok || d.Location == nil || !strings.HasSuffix(d.Location.Href, compressSuffix(r.config.CompressAlgo)) ||
+				d.DatabaseVersion == nil || *d.DatabaseVersion != dbVersion ||
+				d.Checksum == nil || d.Checksum.Type != r.config.ChecksumType ||
+				!d.exists(r.outputDir) {
+				return false
+			}
+			n++
+		}
 	}
 
-	return true
+	return n == len(old.Data)
 }
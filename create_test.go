@@ -0,0 +1,50 @@
+package createrepo
+
+import (
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestCreateUnchanged(t *testing.T) {
+	dir := t.TempDir()
+	writeTestRPM(t, dir+"/a.rpm", &testRPM{Name: "a", Version: "1.0", Release: "1", Arch: "noarch"})
+
+	tests := []struct {
+		Config *Config
+		Expect bool
+	}{
+		{&Config{Zchunk: true, Database: true, CompressVariants: []string{"gz"}}, true},
+		{&Config{Zchunk: true, Database: true, CompressVariants: []string{"gz"}}, false},
+		{&Config{Zchunk: true, CompressVariants: []string{"gz"}}, true},
+		{&Config{Zchunk: true, CompressVariants: []string{"gz"}}, false},
+		{&Config{Zchunk: true, CompressVariants: []string{"gz"}, ChecksumType: "sha1"}, true},
+		{&Config{CompressAlgo: "zst"}, true},
+		{&Config{CompressAlgo: "zst"}, false},
+	}
+
+	for i, test := range tests {
+		test.Config.ChecksumCacheType = "none"
+		r, err := NewRepo(dir, test.Config)
+		if err != nil {
+			t.Fatal(err)
+		}
+		summary, err := r.Create()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if summary.Updated != test.Expect {
+			t.Fatalf("test %d: got updated %t, expected %t", i, summary.Updated, test.Expect)
+		}
+
+		entries, err := os.ReadDir(dir + "/" + repoDataDir)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, e := range entries {
+			if strings.HasSuffix(e.Name(), ".tmp") {
+				t.Fatalf("test %d: temporary file %s left behind", i, e.Name())
+			}
+		}
+	}
+}
@@ -3,6 +3,8 @@ package createrepo
 import (
 	"fmt"
 	"os"
+	"path"
+	"strings"
 )
 
 // IgnoreBadRPMs tells createrepo to ignore RPMs that can't be parsed rather than aborting
@@ -10,67 +12,175 @@ var IgnoreBadRPMs = false
 
 // data represents a data set in repomd.xml
 type data struct {
-	Type         string    `xml:"type,attr"`
-	Checksum     *checksum `xml:"checksum,omitempty"`
-	OpenChecksum *checksum `xml:"open-checksum,omitempty"`
-	Location     *location `xml:"location"`
-	Timestamp    *uint64   `xml:"timestamp"`
-	Size         *uint64   `xml:"size"`
-	OpenSize     *uint64   `xml:"open-size,omitempty"`
+	Type            string    `xml:"type,attr"`
+	Checksum        *checksum `xml:"checksum,omitempty"`
+	OpenChecksum    *checksum `xml:"open-checksum,omitempty"`
+	HeaderChecksum  *checksum `xml:"header-checksum,omitempty"`
+	Location        *location `xml:"location"`
+	Timestamp       *uint64   `xml:"timestamp"`
+	DatabaseVersion *int      `xml:"database_version,omitempty"`
+	Size            *uint64   `xml:"size"`
+	OpenSize        *uint64   `xml:"open-size,omitempty"`
+	HeaderSize      *uint64   `xml:"header-size,omitempty"`
+}
+
+// checkHref returns an error unless href is a clean relative path to
+// a file within repodata.
+func checkHref(href string) error {
+	if href == "" || strings.Contains(href, "\\") ||
+		path.Clean(href) != href ||
+		!strings.HasPrefix(href, repoDataDir+"/") ||
+		strings.HasPrefix(href, repoDataDir+"/../") {
+		return fmt.Errorf("href %q is not within %s", href, repoDataDir)
+	}
+
+	return nil
 }
 
 // sameChecksumAndExists returns true if the two data elements have the same
 // type, checksums and that their href exists.
 func (d *data) sameChecksumAndExists(checksum *checksum, baseDir string) bool {
 	if checksum == nil || d.OpenChecksum == nil ||
+		d.OpenChecksum.Type != checksum.Type ||
 		d.OpenChecksum.Data != checksum.Data {
 		return false
 	}
 
+	return d.exists(baseDir)
+}
+
+// exists returns true if the data has a valid href, and it exists.
+func (d *data) exists(baseDir string) bool {
+	if d.Location == nil || checkHref(d.Location.Href) != nil {
+		return false
+	}
+
 	_, err := os.Stat(baseDir + "/" + d.Location.Href)
 
 	return err == nil
 }
 
+// read returns the uncompressed content of the data file.
+func (d *data) read(baseDir string) ([]byte, error) {
+	if d.Location == nil || d.Location.Href == "" {
+		return nil, fmt.Errorf("data %q has no location", d.Type)
+	}
+	if err := checkHref(d.Location.Href); err != nil {
+		return nil, err
+	}
+
+	content, err := os.ReadFile(baseDir + "/" + d.Location.Href)
+	if err != nil {
+		return nil, err
+	}
+
+	return decompress(content, d.Location.Href)
+}
+
 // dataSet represents all the repository data gathered from the
 // RPMs
 type dataSet struct {
 	primary   *primary
 	fileLists *fileLists
+	other     *other
 	comps     *comps
+	staged    []*stagedFile
+	pruned    []string
 }
 
-// writeData writes meta data to disk and returns an repoMD upon success
-func (r *dataSet) writeData(baseDir, compressAlgo string) (*repoMD, error) {
-	ret := newRepoMD(baseDir)
-
-	cleanUp := true
-	defer func() {
-		if cleanUp {
-			for _, c := range ret.Data {
-				os.Remove(c.Location.Href)
-			}
+// checksum computes the open checksum and size of the data sets,
+// without writing anything. The content is encoded once here, to find
+// out whether it changed, and once more by stage if it did, so an
+// unchanged repository costs no compression.
+func (r *dataSet) checksum(checksumType string) error {
+	var err error
+	if r.primary.OpenChecksum, r.primary.OpenSize, err = openChecksum(checksumType, r.primary.encodeChunks); err != nil {
+		return err
+	}
+	if r.fileLists.OpenChecksum, r.fileLists.OpenSize, err = openChecksum(checksumType, r.fileLists.encodeChunks); err != nil {
+		return err
+	}
+	if r.other.OpenChecksum, r.other.OpenSize, err = openChecksum(checksumType, r.other.encodeChunks); err != nil {
+		return err
+	}
+	if r.comps != nil {
+		if r.comps.OpenChecksum, r.comps.OpenSize, err = openChecksum(checksumType, r.comps.encode); err != nil {
+			return err
 		}
-	}()
+	}
+
+	return nil
+}
 
-	pmeta, err := r.primary.writeData(baseDir, compressAlgo)
+// stage encodes all data sets into compressed temporary files in
+// repodata.
+func (r *dataSet) stage(baseDir string, config *Config) error {
+	s, err := r.primary.stage(baseDir, config)
 	if err != nil {
-		return nil, err
+		return err
 	}
-	ret.Data = append(ret.Data, pmeta)
+	r.staged = append(r.staged, s...)
 
-	fmeta, err := r.fileLists.writeData(baseDir, compressAlgo)
+	s, err = r.fileLists.stage(baseDir, config)
 	if err != nil {
-		return nil, err
+		return err
+	}
+	r.staged = append(r.staged, s...)
+
+	s, err = r.other.stage(baseDir, config)
+	if err != nil {
+		return err
 	}
-	ret.Data = append(ret.Data, fmeta)
+	r.staged = append(r.staged, s...)
 
 	if r.comps != nil {
-		cmeta, err := r.comps.writeData(baseDir, compressAlgo)
+		s, err = r.comps.stage(baseDir, config)
+		if err != nil {
+			return err
+		}
+		r.staged = append(r.staged, s...)
+	}
+
+	if config.Database {
+		if err := r.stageDatabases(baseDir, config); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// discard removes the temporary files of data sets not written.
+func (r *dataSet) discard() {
+	for _, s := range r.staged {
+		s.discard()
+	}
+}
+
+// writeData moves the staged meta data in place and returns an
+// repoMD upon success
+func (r *dataSet) writeData(baseDir string) (*repoMD, error) {
+	ret := newRepoMD(baseDir)
+
+	var created []string
+	cleanUp := true
+	defer func() {
+		if cleanUp {
+			for _, name := range created {
+				os.Remove(name)
+			}
+		}
+	}()
+
+	for _, s := range r.staged {
+		d, isNew, err := s.commit(baseDir)
 		if err != nil {
 			return nil, err
 		}
-		ret.Data = append(ret.Data, cmeta)
+		if isNew {
+			created = append(created, baseDir+"/"+d.Location.Href)
+		}
+		ret.Data = append(ret.Data, d)
 	}
 
 	cleanUp = false
@@ -78,27 +188,83 @@ func (r *dataSet) writeData(baseDir, compressAlgo string) (*repoMD, error) {
 	return ret, nil
 }
 
-// getData returns datasets for primary, filelists and comps (if specified)
-func (r *Repo) getData() (*dataSet, error) {
+// baseURL returns the xml:base of the named RPM.
+func (r *Repo) baseURL(name string) string {
+	if r.config.PackageBaseURL != nil {
+		if base := r.config.PackageBaseURL(strings.TrimPrefix(name, "/")); base != "" {
+			return base
+		}
+	}
 
-	ls, err := getRPMFiles(r.baseDir)
-	if err != nil {
-		return nil, fmt.Errorf("getRPMFileNames: %v", err)
+	return r.config.BaseURL
+}
+
+// getData returns datasets for primary, filelists, other and comps
+// (if specified) for the named RPMs
+func (r *Repo) getData(ls []string) (*dataSet, error) {
+	var cache map[string]*cachedPackage
+	if r.config.Update && !r.config.Rehash {
+		var err error
+		cache, err = r.readCachedPackages()
+		if err != nil {
+			return nil, fmt.Errorf("update: %v", err)
+		}
+	}
+
+	results := make([]*parseResult, len(ls))
+	var parse []int
+	for i, name := range ls {
+		if c, ok := cache[name]; ok && c.unchanged(r.baseDir, name, r.config.ChecksumType) {
+			c.primary.Location.Href = escapeHref(strings.TrimPrefix(name, "/"))
+			results[i] = &parseResult{primary: c.primary, fileList: c.fileList, other: c.other}
+			continue
+		}
+		parse = append(parse, i)
 	}
 
+	r.parsePackages(ls, parse, results)
+
+	if err := r.cache.Flush(); err != nil {
+		return nil, fmt.Errorf("checksum cache: %v", err)
+	}
+
+	var names []string
+	var parsed []*parseResult
 	var packages []*rpmPackage
-	var files []*packageList
-	for _, name := range ls {
-		p, f, err := getPackage(r.baseDir, name)
-		if err != nil {
+	for i, res := range results {
+		if res == nil {
+			continue
+		}
+		if res.err != nil {
 			if IgnoreBadRPMs {
-				fmt.Fprintf(os.Stderr, "getPackage: %s: %v: error is ignored, but this can cause requirement errors in repo\n", name, err)
+				fmt.Fprintf(os.Stderr, "getPackage: %s: %v: error is ignored, but this can cause requirement errors in repo\n", ls[i], res.err)
 				continue
 			}
-			return nil, fmt.Errorf("getPackage: %s: %v", name, err)
+			return nil, fmt.Errorf("getPackage: %s: %v", ls[i], res.err)
 		}
-		packages = append(packages, p)
-		files = append(files, f)
+		res.primary.Location.Base = r.baseURL(ls[i])
+		names = append(names, ls[i])
+		parsed = append(parsed, res)
+		packages = append(packages, res.primary)
+	}
+
+	var keep []bool
+	if r.config.KeepVersions > 0 {
+		keep = keepNewest(packages, r.config.KeepVersions)
+	}
+
+	var pruned []string
+	var files []*packageList
+	var others []*otherPackage
+	packages = nil
+	for i, res := range parsed {
+		if keep != nil && !keep[i] {
+			pruned = append(pruned, names[i])
+			continue
+		}
+		packages = append(packages, res.primary)
+		files = append(files, res.fileList)
+		others = append(others, res.other)
 	}
 
 	meta := &dataSet{
@@ -114,6 +280,13 @@ func (r *Repo) getData() (*dataSet, error) {
 			Count:     fmt.Sprintf("%d", len(packages)),
 			Packages:  files,
 		},
+		other: &other{
+			Type:      "other",
+			Namespace: "http://linux.duke.edu/metadata/other",
+			Count:     fmt.Sprintf("%d", len(packages)),
+			Packages:  others,
+		},
+		pruned: pruned,
 	}
 
 	if r.config.CompsFile != "" {
@@ -124,17 +297,7 @@ func (r *Repo) getData() (*dataSet, error) {
 		meta.comps = c
 	}
 
-	if b, err := meta.primary.XML(); err == nil {
-		meta.primary.OpenChecksum = getChecksumOfBytes(b)
-		meta.primary.OpenSize = uint64(len(b))
-	} else {
-		return nil, err
-	}
-
-	if b, err := meta.fileLists.XML(); err == nil {
-		meta.fileLists.OpenChecksum = getChecksumOfBytes(b)
-		meta.fileLists.OpenSize = uint64(len(b))
-	} else {
+	if err := meta.checksum(r.config.ChecksumType); err != nil {
 		return nil, err
 	}
 
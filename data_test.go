@@ -0,0 +1,29 @@
+package createrepo
+
+import (
+	"testing"
+)
+
+func TestCheckHref(t *testing.T) {
+	tests := map[string]bool{
+		"repodata/0123-primary.xml.xz": true,
+		"repodata/sub/file":            true,
+		"":                             false,
+		"repodata":                     false,
+		"repodata/":                    false,
+		"/etc/passwd":                  false,
+		"../../etc/passwd":             false,
+		"repodata/../../etc/passwd":    false,
+		"repodata/../repomd.xml":       false,
+		"repodata/./file":              false,
+		"repodata//file":               false,
+		"repodata\\..\\file":           false,
+		"x86_64/repodata/file":         false,
+	}
+
+	for href, ok := range tests {
+		if err := checkHref(href); (err == nil) != ok {
+			t.Fatalf("checkHref(%q): got %v, expected ok %t", href, err, ok)
+		}
+	}
+}
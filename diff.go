@@ -0,0 +1,301 @@
+package createrepo
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// RepoDiff represents the differences between the packages of two
+// repositories.
+type RepoDiff struct {
+	// Added and Removed list the packages only in the new and the
+	// old repository, as name-[epoch:]version-release.arch.
+	Added   []string `json:"added,omitempty"`
+	Removed []string `json:"removed,omitempty"`
+
+	// Upgraded and Downgraded list the packages with a newer and an
+	// older version in the new repository.
+	Upgraded   []*PackageDiff `json:"upgraded,omitempty"`
+	Downgraded []*PackageDiff `json:"downgraded,omitempty"`
+
+	// Changed lists the packages with the same version in both
+	// repositories, but different content, e.g. rebuilds.
+	Changed []*PackageDiff `json:"changed,omitempty"`
+}
+
+// PackageDiff represents the differences between two versions of a
+// package with the same name and arch.
+type PackageDiff struct {
+	Name         string            `json:"name"`
+	Arch         string            `json:"arch"`
+	From         string            `json:"from"`
+	To           string            `json:"to"`
+	Dependencies []*DependencyDiff `json:"dependencies,omitempty"`
+	AddedFiles   []string          `json:"addedFiles,omitempty"`
+	RemovedFiles []string          `json:"removedFiles,omitempty"`
+}
+
+// DependencyDiff represents the changes to one type of dependency,
+// e.g. requires.
+type DependencyDiff struct {
+	Type    string   `json:"type"`
+	Added   []string `json:"added,omitempty"`
+	Removed []string `json:"removed,omitempty"`
+}
+
+// Diff returns the differences between the packages of the from and
+// the to repository. Packages are matched by name and arch, and their
+// versions compared with CompareEVR.
+func Diff(from, to *Repository) *RepoDiff {
+	oldGroups := groupPackages(from)
+	newGroups := groupPackages(to)
+
+	keys := make(map[string]bool)
+	for key := range oldGroups {
+		keys[key] = true
+	}
+	for key := range newGroups {
+		keys[key] = true
+	}
+	var sorted []string
+	for key := range keys {
+		sorted = append(sorted, key)
+	}
+	sort.Strings(sorted)
+
+	d := &RepoDiff{}
+	for _, key := range sorted {
+		d.diffGroup(oldGroups[key], newGroups[key])
+	}
+
+	return d
+}
+
+// groupPackages returns the packages of the repository grouped by
+// name and arch. A package listed more than once is only included
+// once.
+func groupPackages(r *Repository) map[string][]*Package {
+	ret := make(map[string][]*Package)
+	seen := make(map[string]bool)
+	for _, pkg := range r.packages {
+		if seen[pkg.NEVRA()] {
+			continue
+		}
+		seen[pkg.NEVRA()] = true
+		key := pkg.Name + "." + pkg.Arch
+		ret[key] = append(ret[key], pkg)
+	}
+
+	return ret
+}
+
+// diffGroup adds the differences between the old and the current versions
+// of a package name and arch. Versions in both, equal by CompareEVR
+// rather than by text, are compared by content, see samePackage. Of the
+// rest, the newest old and new versions are paired as upgrades or
+// downgrades, until either runs out.
+func (d *RepoDiff) diffGroup(old, cur []*Package) {
+	paired := make(map[*Package]bool)
+	var oldOnly, newOnly []*Package
+	for _, pkg := range old {
+		var n *Package
+		for _, c := range cur {
+			if !paired[c] && CompareEVR(pkg.EVR(), c.EVR()) == 0 {
+				n = c
+				break
+			}
+		}
+		if n == nil {
+			oldOnly = append(oldOnly, pkg)
+			continue
+		}
+		paired[n] = true
+		if !samePackage(pkg, n) {
+			d.Changed = append(d.Changed, diffPackage(pkg, n))
+		}
+	}
+	for _, pkg := range cur {
+		if !paired[pkg] {
+			newOnly = append(newOnly, pkg)
+		}
+	}
+
+	newestFirst := func(ls []*Package) {
+		sort.SliceStable(ls, func(i, j int) bool {
+			return CompareEVR(ls[i].EVR(), ls[j].EVR()) > 0
+		})
+	}
+	newestFirst(oldOnly)
+	newestFirst(newOnly)
+
+	for len(oldOnly) > 0 && len(newOnly) > 0 {
+		o, n := oldOnly[0], newOnly[0]
+		oldOnly, newOnly = oldOnly[1:], newOnly[1:]
+		if CompareEVR(n.EVR(), o.EVR()) > 0 {
+			d.Upgraded = append(d.Upgraded, diffPackage(o, n))
+		} else {
+			d.Downgraded = append(d.Downgraded, diffPackage(o, n))
+		}
+	}
+
+	for _, pkg := range oldOnly {
+		d.Removed = append(d.Removed, pkg.NEVRA())
+	}
+	for _, pkg := range newOnly {
+		d.Added = append(d.Added, pkg.NEVRA())
+	}
+}
+
+// samePackage returns true if the packages have the same content. The
+// checksums are compared if of the same type, and otherwise the
+// metadata.
+func samePackage(old, cur *Package) bool {
+	if old.ChecksumType == cur.ChecksumType {
+		return old.Checksum == cur.Checksum
+	}
+
+	p := diffPackage(old, cur)
+
+	return len(p.Dependencies) == 0 && len(p.AddedFiles) == 0 && len(p.RemovedFiles) == 0 &&
+		old.Size == cur.Size && old.InstalledSize == cur.InstalledSize && old.ArchiveSize == cur.ArchiveSize &&
+		old.BuildTime.Equal(cur.BuildTime) && old.BuildHost == cur.BuildHost && old.SourceRPM == cur.SourceRPM &&
+		old.Summary == cur.Summary && old.Description == cur.Description
+}
+
+// diffPackage returns the differences between an old and a current
+// version of a package.
+func diffPackage(old, cur *Package) *PackageDiff {
+	p := &PackageDiff{
+		Name: cur.Name,
+		Arch: cur.Arch,
+		From: old.EVR(),
+		To:   cur.EVR(),
+	}
+
+	for _, t := range []struct {
+		Type     string
+		Old, New []*Dependency
+	}{
+		{"provides", old.Provides, cur.Provides},
+		{"requires", old.Requires, cur.Requires},
+		{"conflicts", old.Conflicts, cur.Conflicts},
+		{"obsoletes", old.Obsoletes, cur.Obsoletes},
+		{"suggests", old.Suggests, cur.Suggests},
+		{"recommends", old.Recommends, cur.Recommends},
+	} {
+		added, removed := diffStrings(dependencyStrings(t.Old), dependencyStrings(t.New))
+		if len(added) > 0 || len(removed) > 0 {
+			p.Dependencies = append(p.Dependencies, &DependencyDiff{Type: t.Type, Added: added, Removed: removed})
+		}
+	}
+
+	p.AddedFiles, p.RemovedFiles = diffStrings(filePaths(old.Files), filePaths(cur.Files))
+
+	return p
+}
+
+// dependencyStrings returns the dependencies as strings.
+func dependencyStrings(deps []*Dependency) []string {
+	var ret []string
+	for _, d := range deps {
+		ret = append(ret, d.String())
+	}
+
+	return ret
+}
+
+// filePaths returns the paths of the files.
+func filePaths(files []*File) []string {
+	var ret []string
+	for _, f := range files {
+		ret = append(ret, f.Path)
+	}
+
+	return ret
+}
+
+// diffStrings returns the sorted strings only in cur, and only in old.
+func diffStrings(old, cur []string) ([]string, []string) {
+	oldSet := make(map[string]bool)
+	for _, s := range old {
+		oldSet[s] = true
+	}
+	newSet := make(map[string]bool)
+	for _, s := range cur {
+		newSet[s] = true
+	}
+
+	var added, removed []string
+	for s := range newSet {
+		if !oldSet[s] {
+			added = append(added, s)
+		}
+	}
+	for s := range oldSet {
+		if !newSet[s] {
+			removed = append(removed, s)
+		}
+	}
+	sort.Strings(added)
+	sort.Strings(removed)
+
+	return added, removed
+}
+
+// Empty returns true if there are no differences.
+func (d *RepoDiff) Empty() bool {
+	return len(d.Added) == 0 && len(d.Removed) == 0 &&
+		len(d.Upgraded) == 0 && len(d.Downgraded) == 0 && len(d.Changed) == 0
+}
+
+// JSON returns the differences in JSON format.
+func (d *RepoDiff) JSON() ([]byte, error) {
+	b, err := json.MarshalIndent(d, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+
+	return append(b, '\n'), nil
+}
+
+// String returns the differences in text format.
+func (d *RepoDiff) String() string {
+	var b strings.Builder
+
+	for _, nevra := range d.Added {
+		fmt.Fprintf(&b, "added: %s\n", nevra)
+	}
+	for _, nevra := range d.Removed {
+		fmt.Fprintf(&b, "removed: %s\n", nevra)
+	}
+	for _, s := range []struct {
+		Label    string
+		Packages []*PackageDiff
+	}{
+		{"upgraded", d.Upgraded},
+		{"downgraded", d.Downgraded},
+		{"changed", d.Changed},
+	} {
+		for _, p := range s.Packages {
+			fmt.Fprintf(&b, "%s: %s.%s %s -> %s\n", s.Label, p.Name, p.Arch, p.From, p.To)
+			for _, dep := range p.Dependencies {
+				for _, name := range dep.Added {
+					fmt.Fprintf(&b, "  + %s: %s\n", dep.Type, name)
+				}
+				for _, name := range dep.Removed {
+					fmt.Fprintf(&b, "  - %s: %s\n", dep.Type, name)
+				}
+			}
+			for _, name := range p.AddedFiles {
+				fmt.Fprintf(&b, "  + file: %s\n", name)
+			}
+			for _, name := range p.RemovedFiles {
+				fmt.Fprintf(&b, "  - file: %s\n", name)
+			}
+		}
+	}
+
+	return b.String()
+}
@@ -0,0 +1,124 @@
+package createrepo
+
+import (
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestDiffStrings(t *testing.T) {
+	added, removed := diffStrings([]string{"c", "a", "b", "a"}, []string{"d", "b", "e", "b"})
+	if got := strings.Join(added, ",") + " " + strings.Join(removed, ","); got != "d,e a,c" {
+		t.Fatalf("got %q, expected %q", got, "d,e a,c")
+	}
+
+	added, removed = diffStrings(nil, nil)
+	if added != nil || removed != nil {
+		t.Fatalf("got %q and %q, expected nothing", added, removed)
+	}
+}
+
+func TestDiffGroup(t *testing.T) {
+	pkg := func(evr string, checksum ...string) *Package {
+		p := &Package{Name: "a", Arch: "noarch", Version: evr, Release: "1", ChecksumType: "sha256", Checksum: evr}
+		if len(checksum) == 2 {
+			p.ChecksumType, p.Checksum = checksum[0], checksum[1]
+		}
+		if n := strings.Index(evr, ":"); n > 0 {
+			p.Epoch = int(atoi(evr[:n]))
+			p.Version = evr[n+1:]
+		}
+		return p
+	}
+	rebuilt := pkg("5.0", "sha256", "other")
+	rebuilt.Requires = []*Dependency{{Name: "b"}}
+	resized := pkg("6.0", "sha1", "other")
+	resized.Size = 100
+
+	tests := []struct {
+		Old, New []*Package
+		Expect   string
+	}{
+		{[]*Package{pkg("1.0"), pkg("2.0"), pkg("3.0")}, []*Package{pkg("2.0"), pkg("4.0"), pkg("2.5")},
+			"upgraded: a.noarch 3.0-1 -> 4.0-1\nupgraded: a.noarch 1.0-1 -> 2.5-1\n"},
+		{[]*Package{pkg("3.0"), pkg("1.0")}, []*Package{pkg("2.0")},
+			"removed: a-1.0-1.noarch\ndowngraded: a.noarch 3.0-1 -> 2.0-1\n"},
+		{[]*Package{pkg("2.0")}, []*Package{pkg("1:1.0"), pkg("3.0")},
+			"added: a-3.0-1.noarch\nupgraded: a.noarch 2.0-1 -> 1:1.0-1\n"},
+		{nil, []*Package{pkg("1.0")}, "added: a-1.0-1.noarch\n"},
+		{[]*Package{pkg("1.0")}, nil, "removed: a-1.0-1.noarch\n"},
+		{[]*Package{pkg("5.0")}, []*Package{rebuilt}, "changed: a.noarch 5.0-1 -> 5.0-1\n  + requires: b\n"},
+		{[]*Package{pkg("5.0")}, []*Package{pkg("5.0", "sha1", "other")}, ""},
+		{[]*Package{pkg("6.0")}, []*Package{resized}, "changed: a.noarch 6.0-1 -> 6.0-1\n"},
+		{[]*Package{pkg("1.0")}, []*Package{pkg("1.00")}, "changed: a.noarch 1.0-1 -> 1.00-1\n"},
+		{[]*Package{pkg("1.0")}, []*Package{pkg("1.00", "sha256", "1.0")}, ""},
+		{[]*Package{pkg("1.0"), pkg("01.0")}, []*Package{pkg("1.00"), pkg("2.0")},
+			"upgraded: a.noarch 01.0-1 -> 2.0-1\nchanged: a.noarch 1.0-1 -> 1.00-1\n"},
+	}
+
+	for i, test := range tests {
+		d := &RepoDiff{}
+		d.diffGroup(test.Old, test.New)
+		if got := d.String(); got != test.Expect {
+			t.Fatalf("test %d: got\n%s\nexpected\n%s", i, got, test.Expect)
+		}
+	}
+}
+
+func TestDiffRevisions(t *testing.T) {
+	dir := t.TempDir()
+	writeTestRPM(t, dir+"/a.rpm", &testRPM{Name: "a", Version: "1.0", Release: "1", Arch: "noarch",
+		Requires: []string{"b"}, Files: []string{"/usr/bin/a"}})
+	writeTestRPM(t, dir+"/b.rpm", &testRPM{Name: "b", Version: "1.0", Release: "1", Arch: "noarch"})
+
+	r, err := NewRepo(dir, &Config{ChecksumCacheType: "none", ExpungeOldMetadata: 3600})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := r.Create(); err != nil {
+		t.Fatal(err)
+	}
+	first, err := r.readRepoMD()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	writeTestRPM(t, dir+"/a.rpm", &testRPM{Name: "a", Version: "2.0", Release: "1", Arch: "noarch",
+		Requires: []string{"c >= 1.0"}, Files: []string{"/usr/bin/a", "/usr/bin/a2"}})
+	if err := os.Remove(dir + "/b.rpm"); err != nil {
+		t.Fatal(err)
+	}
+	writeTestRPM(t, dir+"/c.rpm", &testRPM{Name: "c", Version: "1.0", Release: "1", Arch: "noarch"})
+	if _, err := r.Create(); err != nil {
+		t.Fatal(err)
+	}
+
+	old, err := OpenRevision(dir, int64(first.Revision))
+	if err != nil {
+		t.Fatal(err)
+	}
+	cur, err := OpenRepoMD(dir + "/repodata/repomd.xml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if old.Revision() == cur.Revision() {
+		t.Fatalf("got the same revision %d", cur.Revision())
+	}
+
+	expect := "added: c-1.0-1.noarch\n" +
+		"removed: b-1.0-1.noarch\n" +
+		"upgraded: a.noarch 1.0-1 -> 2.0-1\n" +
+		"  + requires: c >= 1.0\n" +
+		"  - requires: b\n" +
+		"  + file: /usr/bin/a2\n"
+	if got := Diff(old, cur).String(); got != expect {
+		t.Fatalf("got\n%s\nexpected\n%s", got, expect)
+	}
+	if d := Diff(cur, cur); !d.Empty() {
+		t.Fatalf("got differences of the same revision: %s", d)
+	}
+
+	if _, err := OpenRevision(dir, 1); err == nil {
+		t.Fatalf("got no error for an unknown revision")
+	}
+}
@@ -0,0 +1,196 @@
+package createrepo
+
+import (
+	"strings"
+)
+
+// EVR represents the epoch, version and release of a package.
+type EVR struct {
+	Epoch   string
+	Version string
+	Release string
+}
+
+// ParseEVR parses [epoch:]version[-release] as rpm does. The epoch is
+// the leading digits before a colon, and the release is what follows
+// the last hyphen. An empty epoch before the colon is 0.
+func ParseEVR(s string) EVR {
+	var evr EVR
+
+	i := 0
+	for i < len(s) && isDigit(s[i]) {
+		i++
+	}
+	if i < len(s) && s[i] == ':' {
+		evr.Epoch = s[:i]
+		if evr.Epoch == "" {
+			evr.Epoch = "0"
+		}
+		s = s[i+1:]
+	}
+
+	if n := strings.LastIndex(s, "-"); n >= 0 {
+		evr.Release = s[n+1:]
+		s = s[:n]
+	}
+	evr.Version = s
+
+	return evr
+}
+
+// String returns the EVR as [epoch:]version[-release].
+func (e EVR) String() string {
+	s := e.Version
+	if e.Epoch != "" {
+		s = e.Epoch + ":" + s
+	}
+	if e.Release != "" {
+		s += "-" + e.Release
+	}
+
+	return s
+}
+
+// Compare returns -1, 0 or 1 if e is older than, equal to or newer than
+// o. A missing epoch is 0, and the releases are only compared if both
+// are present, as in rpm dependency comparisons.
+func (e EVR) Compare(o EVR) int {
+	ea, eb := e.Epoch, o.Epoch
+	if ea == "" {
+		ea = "0"
+	}
+	if eb == "" {
+		eb = "0"
+	}
+
+	if c := rpmvercmp(ea, eb); c != 0 {
+		return c
+	}
+	if c := rpmvercmp(e.Version, o.Version); c != 0 {
+		return c
+	}
+	if e.Release != "" && o.Release != "" {
+		return rpmvercmp(e.Release, o.Release)
+	}
+
+	return 0
+}
+
+// CompareEVR returns -1, 0 or 1 if the [epoch:]version[-release] a is
+// older than, equal to or newer than b.
+func CompareEVR(a, b string) int {
+	return ParseEVR(a).Compare(ParseEVR(b))
+}
+
+// rpmvercmp compares two version or release strings the way rpm
+// does. The strings are split into segments of digits and letters,
+// and other characters separate segments. Numeric segments are newer
+// than alphabetic ones. A tilde sorts before anything, even the end of
+// the string, and a caret sorts after the end of the string, but
+// before anything else.
+func rpmvercmp(a, b string) int {
+	if a == b {
+		return 0
+	}
+
+	for len(a) > 0 || len(b) > 0 {
+		a = strings.TrimLeftFunc(a, isSeparator)
+		b = strings.TrimLeftFunc(b, isSeparator)
+
+		if strings.HasPrefix(a, "~") || strings.HasPrefix(b, "~") {
+			if !strings.HasPrefix(a, "~") {
+				return 1
+			}
+			if !strings.HasPrefix(b, "~") {
+				return -1
+			}
+			a, b = a[1:], b[1:]
+			continue
+		}
+
+		if strings.HasPrefix(a, "^") || strings.HasPrefix(b, "^") {
+			if a == "" {
+				return -1
+			}
+			if b == "" {
+				return 1
+			}
+			if !strings.HasPrefix(a, "^") {
+				return 1
+			}
+			if !strings.HasPrefix(b, "^") {
+				return -1
+			}
+			a, b = a[1:], b[1:]
+			continue
+		}
+
+		if a == "" || b == "" {
+			break
+		}
+
+		isNum := isDigit(a[0])
+		segment := isAlpha
+		if isNum {
+			segment = isDigit
+		}
+
+		i := 0
+		for i < len(a) && segment(a[i]) {
+			i++
+		}
+		j := 0
+		for j < len(b) && segment(b[j]) {
+			j++
+		}
+		sa, sb := a[:i], b[:j]
+		a, b = a[i:], b[j:]
+
+		// Segments of different types: numeric is newer
+		if sb == "" {
+			if isNum {
+				return 1
+			}
+			return -1
+		}
+
+		if isNum {
+			sa = strings.TrimLeft(sa, "0")
+			sb = strings.TrimLeft(sb, "0")
+			if len(sa) > len(sb) {
+				return 1
+			}
+			if len(sa) < len(sb) {
+				return -1
+			}
+		}
+
+		if c := strings.Compare(sa, sb); c != 0 {
+			return c
+		}
+	}
+
+	if a == "" && b == "" {
+		return 0
+	}
+	if a == "" {
+		return -1
+	}
+
+	return 1
+}
+
+// isSeparator returns true for characters separating version segments.
+func isSeparator(r rune) bool {
+	return r > 127 || !isDigit(byte(r)) && !isAlpha(byte(r)) && r != '~' && r != '^'
+}
+
+// isDigit returns true for ASCII digits.
+func isDigit(c byte) bool {
+	return '0' <= c && c <= '9'
+}
+
+// isAlpha returns true for ASCII letters.
+func isAlpha(c byte) bool {
+	return 'a' <= c && c <= 'z' || 'A' <= c && c <= 'Z'
+}
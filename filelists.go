@@ -28,33 +28,20 @@ func (f *fileLists) String() string {
 	return string(b)
 }
 
-// writeData returns the metadata and file content of the fileLists section
-func (f *fileLists) writeData(baseDir, compressAlgo string) (*data, error) {
-	x, err := f.XML()
-	if err != nil {
-		return nil, err
-	}
-	compressed, checksum, suffix, err := compress(x, compressAlgo)
-	if err != nil {
-		return nil, err
-	}
-
-	size := uint64(len(compressed))
-	href := repoDataDir + "/" + checksum.Data + "-filelists.xml" + suffix
-	modTime, err := writeFile(baseDir+"/"+href, compressed)
-	if err != nil {
-		return nil, err
-	}
+// stage encodes the fileLists into compressed temporary files, one per
+// compression algorithm
+func (f *fileLists) stage(baseDir string, config *Config) ([]*stagedFile, error) {
+	return stageFiles(baseDir, "filelists", "filelists.xml", config.chunkedCompressAlgos(), config.CompressLevel, config.ChecksumType, f.encodeChunks)
+}
 
-	data := &data{
-		Type:         "filelists",
-		Checksum:     checksum,
-		OpenChecksum: f.OpenChecksum,
-		Location:     &location{Href: href},
-		Size:         &size,
-		OpenSize:     &f.OpenSize,
-		Timestamp:    &modTime,
-	}
+// encodeChunks encodes the fileLists with one chunk per package
+func (f *fileLists) encodeChunks(w chunkWriter) error {
+	root := xml.StartElement{Name: xml.Name{Local: "filelists"}, Attr: []xml.Attr{
+		{Name: xml.Name{Local: "xmlns"}, Value: f.Namespace},
+		{Name: xml.Name{Local: "packages"}, Value: f.Count},
+	}}
 
-	return data, nil
+	return encodeXMLChunks(w, root, len(f.Packages), func(i int) (any, xml.StartElement) {
+		return f.Packages[i], xml.StartElement{Name: xml.Name{Local: "package"}}
+	})
 }
@@ -1,11 +1,16 @@
 package createrepo
 
 import (
+	"bytes"
+	"crypto/sha1"
 	"crypto/sha256"
+	"crypto/sha512"
 	"encoding/xml"
 	"fmt"
+	"hash"
 	"io"
 	"os"
+	"strings"
 )
 
 const (
@@ -13,13 +18,71 @@ const (
 )
 
 func xmlencode(a any) ([]byte, error) {
-	b, err := xml.MarshalIndent(a, "", "  ")
-	if err != nil {
+	var buf bytes.Buffer
+	if err := encodeXML(&buf, a); err != nil {
 		return nil, err
 	}
-	b = append(b, '\n')
 
-	return append([]byte(xmlHeader), b...), nil
+	return buf.Bytes(), nil
+}
+
+// encodeXML writes the XML header and the indented XML encoding of a
+// to w.
+func encodeXML(w io.Writer, a any) error {
+	if _, err := io.WriteString(w, xmlHeader); err != nil {
+		return err
+	}
+
+	e := xml.NewEncoder(w)
+	e.Indent("", "  ")
+	if err := e.Encode(a); err != nil {
+		return err
+	}
+
+	_, err := io.WriteString(w, "\n")
+
+	return err
+}
+
+// encodeXMLChunks writes the same document as encodeXML, but element
+// by element: the XML header and the start of root, then each of the
+// n elements returned by item, and at last the end of root. A chunk is
+// ended after the root start and after each element.
+func encodeXMLChunks(w chunkWriter, root xml.StartElement, n int, item func(int) (any, xml.StartElement)) error {
+	if _, err := io.WriteString(w, xmlHeader); err != nil {
+		return err
+	}
+
+	e := xml.NewEncoder(w)
+	e.Indent("", "  ")
+	if err := e.EncodeToken(root); err != nil {
+		return err
+	}
+
+	for i := 0; i < n; i++ {
+		if err := e.Flush(); err != nil {
+			return err
+		}
+		if err := w.endChunk(); err != nil {
+			return err
+		}
+
+		v, start := item(i)
+		if err := e.EncodeElement(v, start); err != nil {
+			return err
+		}
+	}
+
+	if err := e.EncodeToken(root.End()); err != nil {
+		return err
+	}
+	if err := e.Flush(); err != nil {
+		return err
+	}
+
+	_, err := io.WriteString(w, "\n")
+
+	return err
 }
 
 func writeFile(name string, data []byte) (uint64, error) {
@@ -41,33 +104,77 @@ func writeFile(name string, data []byte) (uint64, error) {
 	return uint64(fi.ModTime().Unix()), nil
 }
 
-func getChecksumOfFile(name string) (*checksum, error) {
+func getChecksumOfFile(name, checksumType string) (*checksum, error) {
+	h, err := newHash(checksumType)
+	if err != nil {
+		return nil, err
+	}
+
 	f, err := os.Open(name)
 	if err != nil {
 		return nil, err
 	}
 	defer f.Close()
 
-	h := sha256.New()
 	if _, err := io.Copy(h, f); err != nil {
 		return nil, err
 	}
 
-	return &checksum{
-		Type:  "sha256",
-		PkgID: "YES",
-		Data:  fmt.Sprintf("%x", h.Sum(nil)),
-	}, nil
+	return newChecksum(checksumType, h), nil
 }
 
-func getChecksumOfBytes(data []byte) *checksum {
-	h := sha256.New()
+// newHash returns a new hash for the named checksum type. Supported
+// types are: sha1, sha224, sha256, sha384 and sha512.
+func newHash(checksumType string) (hash.Hash, error) {
+	switch checksumType {
+	case "sha1":
+		return sha1.New(), nil
+	case "sha224":
+		return sha256.New224(), nil
+	case "sha256":
+		return sha256.New(), nil
+	case "sha384":
+		return sha512.New384(), nil
+	case "sha512":
+		return sha512.New(), nil
+	}
 
-	h.Write(data)
+	return nil, fmt.Errorf("unsupported checksum type: %s", checksumType)
+}
 
+// newChecksum returns the checksum of the data written to h.
+func newChecksum(checksumType string, h hash.Hash) *checksum {
 	return &checksum{
-		Type:  "sha256",
+		Type:  checksumType,
 		PkgID: "YES",
 		Data:  fmt.Sprintf("%x", h.Sum(nil)),
 	}
 }
+
+// countWriter counts the bytes written through it.
+type countWriter struct {
+	n uint64
+}
+
+func (c *countWriter) Write(p []byte) (int, error) {
+	c.n += uint64(len(p))
+	return len(p), nil
+}
+
+// escapeHref returns name percent-encoded for use as a location
+// href. All bytes but ASCII letters, digits, '-', '.', '_', '~' and '/'
+// are encoded, as some servers treat a raw '+' as a space.
+func escapeHref(name string) string {
+	var b strings.Builder
+	for i := 0; i < len(name); i++ {
+		c := name[i]
+		if 'a' <= c && c <= 'z' || 'A' <= c && c <= 'Z' || '0' <= c && c <= '9' ||
+			c == '-' || c == '.' || c == '_' || c == '~' || c == '/' {
+			b.WriteByte(c)
+		} else {
+			fmt.Fprintf(&b, "%%%02X", c)
+		}
+	}
+
+	return b.String()
+}
@@ -0,0 +1,95 @@
+package createrepo
+
+import (
+	"fmt"
+	"os"
+	"path"
+	"sort"
+	"strings"
+	"time"
+)
+
+// defaultGarbageGracePeriod is the default minimum age in seconds of
+// files removed by garbage collection.
+const defaultGarbageGracePeriod = 3600
+
+// CollectGarbage removes files in repodata not referenced by
+// repomd.xml or any revision in history, and older than
+// GarbageGracePeriod. This includes temporary files left behind by
+// interrupted runs. Hidden files, like the history and config files,
+// are left alone. If dryRun is true, nothing is removed. The paths,
+// relative to the output directory, of the files removed (or to be
+// removed) are returned.
+func (r *Repo) CollectGarbage(dryRun bool) ([]string, error) {
+	repomd, err := r.readRepoMD()
+	if err != nil {
+		return nil, fmt.Errorf("repomd: %v", err)
+	}
+	if repomd == nil {
+		return nil, fmt.Errorf("no %s found in %q", repoMDXML, r.outputDir)
+	}
+
+	hist, err := readHistory(r.outputDir)
+	if err != nil {
+		return nil, fmt.Errorf("history: %v", err)
+	}
+
+	referenced := make(map[string]bool)
+	for _, d := range repomd.Data {
+		if d.Location != nil {
+			referenced[path.Clean(d.Location.Href)] = true
+		}
+	}
+	if hist != nil {
+		for _, rev := range hist.Revisions {
+			for _, d := range rev.Data {
+				if d.Location != nil {
+					referenced[path.Clean(d.Location.Href)] = true
+				}
+			}
+		}
+	}
+
+	entries, err := os.ReadDir(r.outputDir + "/" + repoDataDir)
+	if err != nil {
+		return nil, err
+	}
+
+	grace := r.config.GarbageGracePeriod
+	if grace == 0 {
+		grace = defaultGarbageGracePeriod
+	}
+	limit := time.Now().Add(-time.Duration(grace) * time.Second)
+
+	var ret []string
+	for _, e := range entries {
+		name := repoDataDir + "/" + e.Name()
+		if !e.Type().IsRegular() || name == repoMDXML || referenced[name] {
+			continue
+		}
+		if strings.HasPrefix(e.Name(), ".") && !strings.HasSuffix(e.Name(), ".tmp") {
+			continue
+		}
+
+		fi, err := e.Info()
+		if err != nil {
+			if os.IsNotExist(err) {
+				continue
+			}
+			return ret, err
+		}
+		if fi.ModTime().After(limit) {
+			continue
+		}
+
+		if !dryRun {
+			if err := os.Remove(r.outputDir + "/" + name); err != nil && !os.IsNotExist(err) {
+				return ret, err
+			}
+		}
+		ret = append(ret, name)
+	}
+	sort.Strings(ret)
+
+	return ret, nil
+}
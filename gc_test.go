@@ -0,0 +1,126 @@
+package createrepo
+
+import (
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestCollectGarbage(t *testing.T) {
+	dir := t.TempDir()
+	writeTestRPM(t, dir+"/a.rpm", &testRPM{Name: "a", Version: "1.0", Release: "1", Arch: "noarch"})
+
+	r, err := NewRepo(dir, &Config{ChecksumCacheType: "none"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := r.Create(); err != nil {
+		t.Fatal(err)
+	}
+
+	// A revision in history still referencing an old data file
+	hist, err := readHistory(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	hist.Revisions = append(hist.Revisions, &revision{Revision: 100, Obsoleted: 100, Data: []*data{
+		{Type: "primary", Location: &location{Href: "repodata/old-primary.xml.xz"}},
+	}})
+	if err := hist.write(); err != nil {
+		t.Fatal(err)
+	}
+
+	old := time.Now().Add(-2 * time.Hour)
+	ages := map[string]time.Time{
+		"old-primary.xml.xz":   old,
+		"stale-primary.xml.xz": old,
+		"recent.xml.xz":        time.Now().Add(-10 * time.Minute),
+		"fresh.xml.xz":         time.Now(),
+		".hidden":              old,
+		".primary.xml.tmp":     old,
+		".fresh.tmp":           time.Now(),
+	}
+	for name, mtime := range ages {
+		if err := os.WriteFile(dir+"/repodata/"+name, nil, 0644); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.Chtimes(dir+"/repodata/"+name, mtime, mtime); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := os.Mkdir(dir+"/repodata/sub", 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chtimes(dir+"/repodata/sub", old, old); err != nil {
+		t.Fatal(err)
+	}
+
+	// The published files are old too
+	entries, err := os.ReadDir(dir + "/repodata")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, e := range entries {
+		if _, ok := ages[e.Name()]; !ok {
+			if err := os.Chtimes(dir+"/repodata/"+e.Name(), old, old); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+
+	tests := []struct {
+		Grace  int64
+		DryRun bool
+		Expect string
+	}{
+		{0, true, "repodata/.primary.xml.tmp, repodata/stale-primary.xml.xz"},
+		{300, true, "repodata/.primary.xml.tmp, repodata/recent.xml.xz, repodata/stale-primary.xml.xz"},
+		{0, false, "repodata/.primary.xml.tmp, repodata/stale-primary.xml.xz"},
+		{0, false, ""},
+		{300, false, "repodata/recent.xml.xz"},
+	}
+
+	for i, test := range tests {
+		r, err := NewRepo(dir, &Config{ChecksumCacheType: "none", GarbageGracePeriod: test.Grace})
+		if err != nil {
+			t.Fatal(err)
+		}
+		garbage, err := r.CollectGarbage(test.DryRun)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := strings.Join(garbage, ", "); got != test.Expect {
+			t.Fatalf("test %d: got %q, expected %q", i, got, test.Expect)
+		}
+		for _, name := range garbage {
+			if _, err := os.Stat(dir + "/" + name); os.IsNotExist(err) == test.DryRun {
+				t.Fatalf("test %d: %s: got exists %t, expected %t", i, name, err == nil, test.DryRun)
+			}
+		}
+	}
+
+	// Everything referenced is still there
+	repo, err := OpenRepo(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if repo.Len() != 1 {
+		t.Fatalf("got %d packages, expected 1", repo.Len())
+	}
+	for _, name := range []string{"old-primary.xml.xz", "fresh.xml.xz", ".hidden", ".fresh.tmp", "sub", ".history.xml"} {
+		if _, err := os.Stat(dir + "/repodata/" + name); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestCollectGarbageWithoutRepoMD(t *testing.T) {
+	r, err := NewRepo(t.TempDir(), &Config{ChecksumCacheType: "none"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := r.CollectGarbage(true); err == nil {
+		t.Fatalf("got no error without %s", repoMDXML)
+	}
+}
@@ -4,6 +4,8 @@ import (
 	"encoding/xml"
 	"fmt"
 	"os"
+	"sort"
+	"strings"
 	"time"
 )
 
@@ -15,20 +17,21 @@ type history struct {
 	Revisions []*revision `xml:"revisions"`
 }
 
-// Append appends a repoMD to the history.
-func (h *history) Append(r *repoMD) {
+// Append appends a repoMD to the history, and returns its revision.
+// A revision already in history is refused, as it lists other data
+// files, see nextRevision.
+func (h *history) Append(r *repoMD) (*revision, error) {
+	if h.find(r.Revision) != nil {
+		return nil, fmt.Errorf("revision %d already in history", int64(r.Revision))
+	}
+
 	e := &revision{
 		Revision: r.Revision,
 	}
 	e.Data = append(e.Data, r.Data...)
-
-	for _, ex := range h.Revisions {
-		if ex.Revision == e.Revision {
-			// Revision already exists
-			return
-		}
-	}
 	h.Revisions = append(h.Revisions, e)
+
+	return e, nil
 }
 
 // write writes the history to the named file, creating it if
@@ -44,9 +47,13 @@ func (h *history) write() error {
 	return nil
 }
 
-// Clean cleans up revisions older than n seconds. If the current
-// *repoMD is passed, it will be spared from the cleanup process.
-func (h *history) Clean(seconds int64) (int, error) {
+// Clean cleans up old revisions obsoleted more than seconds ago. The
+// newest retain old revisions are kept regardless of age, and at most
+// max old revisions are kept, unless max is zero. The current
+// revisions, the newest one and the one published in repomd.xml, are
+// always kept, as are the data files of repomd.xml. The old revisions
+// kept and expunged are returned.
+func (h *history) Clean(seconds int64, retain, max int) ([]int64, []int64, error) {
 	var lastRevision *revision
 	for _, r := range h.Revisions {
 		if lastRevision == nil || r.Revision > lastRevision.Revision {
@@ -54,22 +61,37 @@ func (h *history) Clean(seconds int64) (int, error) {
 		}
 	}
 	if lastRevision == nil {
-		return 0, fmt.Errorf("no current revision found in history")
+		return nil, nil, fmt.Errorf("no current revision found in history")
 	}
 
-	// Bless all data files for lastRevision. One or more data
-	// sets might occur in a dirrerent Revision. The group dataset
-	// might be the same etc.
-	bless := make(map[string]bool)
-	for _, data := range lastRevision.Data {
-		bless[data.Location.Href] = true
+	published, err := readRepoMDFrom(h.baseDir)
+	if err != nil {
+		return nil, nil, fmt.Errorf("repomd: %v", err)
+	}
+	current := func(r *revision) bool {
+		return r.Revision == lastRevision.Revision || published != nil && r.Revision == published.Revision
+	}
+
+	// Rank the old revisions, newest first
+	var old []*revision
+	for _, r := range h.Revisions {
+		if !current(r) {
+			old = append(old, r)
+		}
+	}
+	sort.SliceStable(old, func(i, j int) bool {
+		return old[i].Revision > old[j].Revision
+	})
+	rank := make(map[*revision]int)
+	for i, r := range old {
+		rank[r] = i
 	}
 
 	now := time.Now().Unix()
-	expunged := 0
-	var newRevs []*revision
+	var kept, expunged []int64
+	var newRevs, expiredRevs []*revision
 	for _, r := range h.Revisions {
-		if r.Revision == lastRevision.Revision {
+		if current(r) {
 			newRevs = append(newRevs, r)
 			continue
 		}
@@ -77,34 +99,86 @@ func (h *history) Clean(seconds int64) (int, error) {
 			r.Obsoleted = now
 		}
 
-		if now >= r.Obsoleted+seconds {
-			expunged++
-			for _, data := range r.Data {
-				if data.Location != nil && data.Location.Href != "" {
-					if _, blessed := bless[data.Location.Href]; !blessed {
-						os.Remove(h.baseDir + "/" + data.Location.Href)
-					}
-				}
-			}
+		expunge := now >= r.Obsoleted+seconds
+		if rank[r] < retain {
+			expunge = false
+		}
+		if max > 0 && rank[r] >= max {
+			expunge = true
+		}
+
+		if expunge {
+			expunged = append(expunged, int64(r.Revision))
+			expiredRevs = append(expiredRevs, r)
 		} else {
+			kept = append(kept, int64(r.Revision))
 			newRevs = append(newRevs, r)
+		}
+	}
+
+	// Bless all data files of the revisions kept. One or more
+	// data sets might occur in a different Revision. The group
+	// dataset might be the same etc.
+	bless := make(map[string]bool)
+	if published != nil {
+		for _, data := range published.Data {
+			if data.Location != nil {
+				bless[data.Location.Href] = true
+			}
+		}
+	}
+	for _, r := range newRevs {
+		for _, data := range r.Data {
+			if data.Location != nil {
+				bless[data.Location.Href] = true
+			}
+		}
+	}
 
+	// Never remove anything outside of repodata, whatever the
+	// history says
+	var suspicious []string
+	for _, r := range expiredRevs {
+		for _, data := range r.Data {
+			if data.Location != nil && data.Location.Href != "" {
+				if _, blessed := bless[data.Location.Href]; !blessed {
+					if err := checkHref(data.Location.Href); err != nil {
+						suspicious = append(suspicious, fmt.Sprintf("revision %d: %v", int64(r.Revision), err))
+						continue
+					}
+					os.Remove(h.baseDir + "/" + data.Location.Href)
+				}
+			}
 		}
 	}
 
 	h.Revisions = newRevs
 	if err := h.write(); err != nil {
-		return 0, err
+		return nil, nil, err
 	}
 
-	return expunged, nil
+	if len(suspicious) > 0 {
+		return kept, expunged, fmt.Errorf("history: %s", strings.Join(suspicious, "; "))
+	}
+
+	return kept, expunged, nil
 }
 
 // revision represents a single repoMD in the .history.xml file.
 type revision struct {
-	Obsoleted int64   `xml:"obsoleted,omitempty"`
-	Revision  float64 `xml:"revision"`
-	Data      []*data `xml:"data"`
+	Obsoleted     int64             `xml:"obsoleted,omitempty"`
+	Revision      float64           `xml:"revision"`
+	Rollback      float64           `xml:"rollback,omitempty"`
+	Packages      int               `xml:"packages,omitempty"`
+	PackageDigest *checksum         `xml:"package-digest,omitempty"`
+	Added         []string          `xml:"added>nevra,omitempty"`
+	Removed       []string          `xml:"removed>nevra,omitempty"`
+	Updated       []*revisionUpdate `xml:"updated>nevra,omitempty"`
+	Message       string            `xml:"message,omitempty"`
+	Host          string            `xml:"host,omitempty"`
+	User          string            `xml:"user,omitempty"`
+	Settings      string            `xml:"settings,omitempty"`
+	Data          []*data           `xml:"data"`
 }
 
 func (h *history) String() string {
@@ -148,6 +222,31 @@ func readHistory(baseDir string) (*history, error) {
 	return history, nil
 }
 
+// nextRevision returns rev, or if not greater than every revision in
+// history, the revision following the greatest. Revisions are whole
+// seconds, so runs within a second, or after a rollback, would
+// otherwise get the same or a lower revision than the one published.
+func (h *history) nextRevision(rev float64) float64 {
+	for _, r := range h.Revisions {
+		if r.Revision >= rev {
+			rev = r.Revision + 1
+		}
+	}
+
+	return rev
+}
+
+// find returns the revision, or nil if not found.
+func (h *history) find(rev float64) *revision {
+	for _, r := range h.Revisions {
+		if r.Revision == rev {
+			return r
+		}
+	}
+
+	return nil
+}
+
 // newHistory returns a new *history, the named file will be used when
 // writing history to disk.
 func newHistory(baseDir string) *history {
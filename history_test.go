@@ -0,0 +1,129 @@
+package createrepo
+
+import (
+	"fmt"
+	"os"
+	"testing"
+	"time"
+)
+
+func TestHistoryClean(t *testing.T) {
+	now := time.Now().Unix()
+
+	tests := []struct {
+		Seconds  int64
+		Retain   int
+		Max      int
+		Kept     string
+		Expunged string
+	}{
+		// Revisions 100 and 200 are expired, 300 and 400 not
+		{3600, 0, 0, "[300 400]", "[100 200]"},
+		{3600, 3, 0, "[200 300 400]", "[100]"},
+		{3600, 5, 0, "[100 200 300 400]", "[]"},
+		{3600, 0, 1, "[400]", "[100 200 300]"},
+		{0, 0, 0, "[]", "[100 200 300 400]"},
+		{1 << 40, 0, 3, "[200 300 400]", "[100]"},
+		{1 << 40, 0, 0, "[100 200 300 400]", "[]"},
+		{3600, 3, 3, "[200 300 400]", "[100]"},
+		{3600, 1, 2, "[300 400]", "[100 200]"},
+		{1 << 40, 1, 2, "[300 400]", "[100 200]"},
+	}
+
+	for i, test := range tests {
+		dir := t.TempDir()
+		if err := os.Mkdir(dir+"/"+repoDataDir, 0755); err != nil {
+			t.Fatal(err)
+		}
+
+		h := &history{baseDir: dir}
+		for _, rev := range []int64{100, 200, 300, 400, 500} {
+			obsoleted := now - 7200
+			if rev >= 300 {
+				obsoleted = now - 60
+			}
+			if rev == 500 {
+				obsoleted = 0
+			}
+
+			// The group data is shared by all revisions
+			href := fmt.Sprintf("repodata/%d-primary.xml.xz", rev)
+			for _, name := range []string{href, "repodata/group.xml.xz"} {
+				if err := os.WriteFile(dir+"/"+name, nil, 0644); err != nil {
+					t.Fatal(err)
+				}
+			}
+			h.Revisions = append(h.Revisions, &revision{Revision: float64(rev), Obsoleted: obsoleted, Data: []*data{
+				{Type: "primary", Location: &location{Href: href}},
+				{Type: "group", Location: &location{Href: "repodata/group.xml.xz"}},
+			}})
+		}
+
+		kept, expunged, err := h.Clean(test.Seconds, test.Retain, test.Max)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if fmt.Sprint(kept) != test.Kept || fmt.Sprint(expunged) != test.Expunged {
+			t.Fatalf("test %d: got kept %v and expunged %v, expected %s and %s", i, kept, expunged, test.Kept, test.Expunged)
+		}
+
+		hist, err := readHistory(dir)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got, expect := len(hist.Revisions), len(kept)+1; got != expect {
+			t.Fatalf("test %d: got %d revisions in history, expected %d", i, got, expect)
+		}
+		for _, rev := range expunged {
+			if _, err := os.Stat(fmt.Sprintf("%s/repodata/%d-primary.xml.xz", dir, rev)); !os.IsNotExist(err) {
+				t.Fatalf("test %d: primary of expunged revision %d not removed", i, rev)
+			}
+		}
+		for _, rev := range append(kept, 500) {
+			if _, err := os.Stat(fmt.Sprintf("%s/repodata/%d-primary.xml.xz", dir, rev)); err != nil {
+				t.Fatalf("test %d: primary of kept revision %d: %v", i, rev, err)
+			}
+		}
+		if _, err := os.Stat(dir + "/repodata/group.xml.xz"); err != nil {
+			t.Fatalf("test %d: shared group data: %v", i, err)
+		}
+	}
+}
+
+func TestSummaryString(t *testing.T) {
+	s := &Summary{Dir: "/repo", RPMs: 3, Expunged: 2, ExpungedRevisions: []int64{100, 200}, KeptRevisions: []int64{300}, Pruned: []string{"a.rpm"}, Updated: true}
+	if got, expect := s.String(), "repo:/repo rpms:3 expunged:2(100,200) kept:1(300) pruned:1 repomd:true"; got != expect {
+		t.Fatalf("got %q, expected %q", got, expect)
+	}
+
+	s = &Summary{Dir: "/repo"}
+	if got, expect := s.String(), "repo:/repo rpms:0 expunged:0 kept:0 pruned:0 repomd:false"; got != expect {
+		t.Fatalf("got %q, expected %q", got, expect)
+	}
+}
+
+func TestHistoryAppend(t *testing.T) {
+	h := newHistory(t.TempDir())
+	data := []*data{{Type: "primary", Location: &location{Href: "repodata/a-primary.xml.xz"}}}
+	rev, err := h.Append(&repoMD{Revision: 100, Data: data})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rev.Revision != 100 || len(rev.Data) != 1 || h.find(100) != rev {
+		t.Fatalf("got revision %v with %d data files", rev.Revision, len(rev.Data))
+	}
+
+	if _, err := h.Append(&repoMD{Revision: 100}); err == nil {
+		t.Fatalf("got no error appending an existing revision")
+	}
+	if len(h.Revisions) != 1 || len(h.Revisions[0].Data) != 1 {
+		t.Fatalf("got %d revisions, expected the first one only", len(h.Revisions))
+	}
+
+	if got := h.nextRevision(50); got != 101 {
+		t.Fatalf("got next revision %v, expected 101", got)
+	}
+	if got := h.nextRevision(200); got != 200 {
+		t.Fatalf("got next revision %v, expected 200", got)
+	}
+}
@@ -0,0 +1,12 @@
+//go:build !unix
+
+package createrepo
+
+import (
+	"io/fs"
+)
+
+// fileInode returns zero, as inode numbers are not available.
+func fileInode(fi fs.FileInfo) uint64 {
+	return 0
+}
@@ -0,0 +1,16 @@
+//go:build unix
+
+package createrepo
+
+import (
+	"io/fs"
+	"syscall"
+)
+
+// fileInode returns the inode number of the file.
+func fileInode(fi fs.FileInfo) uint64 {
+	if st, ok := fi.Sys().(*syscall.Stat_t); ok {
+		return uint64(st.Ino)
+	}
+	return 0
+}
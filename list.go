@@ -0,0 +1,91 @@
+package createrepo
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// ReadPackageList reads a package list from the named file, with one
+// path per line. Empty lines and lines starting with # are ignored.
+func ReadPackageList(name string) ([]string, error) {
+	f, err := os.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var ls []string
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		ls = append(ls, line)
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("%s: %v", name, err)
+	}
+
+	return ls, nil
+}
+
+// packageList validates the named RPMs and returns them sorted, without
+// duplicates, as paths relative to the repository directory in the
+// same form as getRPMFiles. Every RPM must be a regular file within
+// the repository directory. Symbolic links must resolve to within the
+// repository directory too, unless AllowExternalSymlinks is set.
+func (r *Repo) packageList(names []string) ([]string, error) {
+	base, err := filepath.Abs(r.baseDir)
+	if err != nil {
+		return nil, err
+	}
+	realBase, err := filepath.EvalSymlinks(base)
+	if err != nil {
+		return nil, err
+	}
+
+	seen := make(map[string]bool)
+	var ls []string
+	for _, name := range names {
+		path := name
+		if !filepath.IsAbs(path) {
+			path = filepath.Join(base, path)
+		}
+
+		rel, err := filepath.Rel(base, filepath.Clean(path))
+		if err != nil || rel == "." || !withinDir(base, path) {
+			return nil, fmt.Errorf("package %q is not within %q", name, r.baseDir)
+		}
+
+		target, err := filepath.EvalSymlinks(filepath.Join(base, rel))
+		if err != nil {
+			return nil, fmt.Errorf("package %q: %v", name, err)
+		}
+		if !r.config.AllowExternalSymlinks && !withinDir(realBase, target) {
+			return nil, fmt.Errorf("package %q links to %q outside of %q", name, target, r.baseDir)
+		}
+
+		fi, err := os.Stat(target)
+		if err != nil {
+			return nil, fmt.Errorf("package %q: %v", name, err)
+		}
+		if !fi.Mode().IsRegular() {
+			return nil, fmt.Errorf("package %q is not a regular file", name)
+		}
+
+		rel = "/" + filepath.ToSlash(rel)
+		if !seen[rel] {
+			seen[rel] = true
+			ls = append(ls, rel)
+		}
+	}
+	sort.Strings(ls)
+
+	return ls, nil
+}
@@ -0,0 +1,115 @@
+package createrepo
+
+import (
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestReadPackageList(t *testing.T) {
+	name := t.TempDir() + "/list"
+	content := "# packages\na.rpm\n\n  sub/b.rpm  \n\t# c.rpm\n/abs/c.rpm\n"
+	if err := os.WriteFile(name, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	ls, err := ReadPackageList(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, expect := strings.Join(ls, ","), "a.rpm,sub/b.rpm,/abs/c.rpm"; got != expect {
+		t.Fatalf("got %q, expected %q", got, expect)
+	}
+
+	if _, err := ReadPackageList(name + ".missing"); err == nil {
+		t.Fatalf("got no error for a missing list")
+	}
+}
+
+func TestPackageList(t *testing.T) {
+	outside := t.TempDir()
+	dir := t.TempDir()
+	for _, name := range []string{dir + "/a.rpm", dir + "/sub/b.rpm", outside + "/c.rpm"} {
+		if err := os.MkdirAll(name[:strings.LastIndex(name, "/")], 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(name, []byte("rpm"), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	links := map[string]string{
+		dir + "/b.rpm":   "sub/b.rpm",
+		dir + "/c.rpm":   outside + "/c.rpm",
+		dir + "/ext":     outside,
+		dir + "/sub.rpm": "sub",
+	}
+	for name, target := range links {
+		if err := os.Symlink(target, name); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tests := []struct {
+		Names         []string
+		AllowExternal bool
+		Expect        string
+		Error         string
+	}{
+		{[]string{"sub/b.rpm", "a.rpm", dir + "/a.rpm", "./sub/../a.rpm"}, false, "/a.rpm,/sub/b.rpm", ""},
+		{[]string{"b.rpm"}, false, "/b.rpm", ""},
+		{[]string{"../c.rpm"}, false, "", "is not within"},
+		{[]string{outside + "/c.rpm"}, false, "", "is not within"},
+		{[]string{"."}, false, "", "is not within"},
+		{[]string{"c.rpm"}, false, "", "outside of"},
+		{[]string{"ext/c.rpm"}, false, "", "outside of"},
+		{[]string{"c.rpm", "ext/c.rpm"}, true, "/c.rpm,/ext/c.rpm", ""},
+		{[]string{"sub.rpm"}, false, "", "is not a regular file"},
+		{[]string{"missing.rpm"}, false, "", "no such file"},
+	}
+
+	for i, test := range tests {
+		r, err := NewRepo(dir, &Config{ChecksumCacheType: "none", AllowExternalSymlinks: test.AllowExternal})
+		if err != nil {
+			t.Fatal(err)
+		}
+		ls, err := r.packageList(test.Names)
+		if test.Error != "" {
+			if err == nil || !strings.Contains(err.Error(), test.Error) {
+				t.Fatalf("test %d: got error %v, expected %q", i, err, test.Error)
+			}
+			continue
+		}
+		if err != nil {
+			t.Fatalf("test %d: %v", i, err)
+		}
+		if got := strings.Join(ls, ","); got != test.Expect {
+			t.Fatalf("test %d: got %q, expected %q", i, got, test.Expect)
+		}
+	}
+}
+
+func TestCreateFromList(t *testing.T) {
+	dir := t.TempDir()
+	writeTestRPM(t, dir+"/a.rpm", &testRPM{Name: "a", Version: "1.0", Release: "1", Arch: "noarch"})
+	writeTestRPM(t, dir+"/b.rpm", &testRPM{Name: "b", Version: "1.0", Release: "1", Arch: "noarch"})
+
+	r, err := NewRepo(dir, &Config{ChecksumCacheType: "none"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	summary, err := r.CreateFromList([]string{"b.rpm"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if summary.RPMs != 1 {
+		t.Fatalf("got %d rpms, expected 1", summary.RPMs)
+	}
+
+	repo, err := OpenRepo(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(repo.Packages()) != 1 || repo.PackageByNEVRA("b-1.0-1.noarch") == nil {
+		t.Fatalf("got %d packages, expected b only", len(repo.Packages()))
+	}
+}
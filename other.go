@@ -0,0 +1,63 @@
+package createrepo
+
+import (
+	"encoding/xml"
+)
+
+// other represents the other repodata holding package changelogs
+type other struct {
+	Type         string          `xml:"-"`
+	XMLName      xml.Name        `xml:"otherdata"`
+	Namespace    string          `xml:"xmlns,attr"`
+	Count        string          `xml:"packages,attr"`
+	Packages     []*otherPackage `xml:"package"`
+	OpenChecksum *checksum       `xml:"-"`
+	OpenSize     uint64          `xml:"-"`
+}
+
+// otherPackage represents a single package in other
+type otherPackage struct {
+	PkgID     string       `xml:"pkgid,attr"`
+	Name      string       `xml:"name,attr"`
+	Arch      string       `xml:"arch,attr"`
+	Version   *version     `xml:"version"`
+	Changelog []*changelog `xml:"changelog"`
+}
+
+// changelog represents a single changelog entry of a package
+type changelog struct {
+	Author string `xml:"author,attr"`
+	Date   int64  `xml:"date,attr"`
+	Text   string `xml:",chardata"`
+}
+
+// XML formats the other to XML
+func (o *other) XML() ([]byte, error) {
+	return xmlencode(o)
+}
+
+func (o *other) String() string {
+	b, err := o.XML()
+	if err != nil {
+		return ""
+	}
+	return string(b)
+}
+
+// stage encodes the other into compressed temporary files, one per
+// compression algorithm
+func (o *other) stage(baseDir string, config *Config) ([]*stagedFile, error) {
+	return stageFiles(baseDir, "other", "other.xml", config.chunkedCompressAlgos(), config.CompressLevel, config.ChecksumType, o.encodeChunks)
+}
+
+// encodeChunks encodes the other with one chunk per package
+func (o *other) encodeChunks(w chunkWriter) error {
+	root := xml.StartElement{Name: xml.Name{Local: "otherdata"}, Attr: []xml.Attr{
+		{Name: xml.Name{Local: "xmlns"}, Value: o.Namespace},
+		{Name: xml.Name{Local: "packages"}, Value: o.Count},
+	}}
+
+	return encodeXMLChunks(w, root, len(o.Packages), func(i int) (any, xml.StartElement) {
+		return o.Packages[i], xml.StartElement{Name: xml.Name{Local: "package"}}
+	})
+}
@@ -0,0 +1,55 @@
+package createrepo
+
+import (
+	"runtime"
+	"sync"
+	"sync/atomic"
+)
+
+// parseResult represents the outcome of parsing a single RPM.
+type parseResult struct {
+	primary  *rpmPackage
+	fileList *packageList
+	other    *otherPackage
+	err      error
+}
+
+// parsePackages parses the RPMs in names given by the indexes in
+// parse concurrently, and stores the outcome at the same index in
+// results. Unless IgnoreBadRPMs is set, no new RPMs are parsed after
+// the first error.
+func (r *Repo) parsePackages(names []string, parse []int, results []*parseResult) {
+	workers := r.config.Workers
+	if workers <= 0 {
+		workers = runtime.GOMAXPROCS(0)
+	}
+	if workers > len(parse) {
+		workers = len(parse)
+	}
+
+	var failed atomic.Bool
+	jobs := make(chan int)
+	var wg sync.WaitGroup
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range jobs {
+				p, f, o, err := getPackage(r.baseDir, names[i], r.config, r.cache)
+				results[i] = &parseResult{primary: p, fileList: f, other: o, err: err}
+				if err != nil && !IgnoreBadRPMs {
+					failed.Store(true)
+				}
+			}
+		}()
+	}
+
+	for _, i := range parse {
+		if failed.Load() {
+			break
+		}
+		jobs <- i
+	}
+	close(jobs)
+	wg.Wait()
+}
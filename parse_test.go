@@ -0,0 +1,71 @@
+package createrepo
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestParsePackages(t *testing.T) {
+	dir := t.TempDir()
+	var expect []string
+	for i := 0; i < 12; i++ {
+		name := fmt.Sprintf("%s/p%02d.rpm", dir, i)
+		if i == 4 || i == 9 {
+			if err := os.WriteFile(name, []byte("not an rpm"), 0644); err != nil {
+				t.Fatal(err)
+			}
+			continue
+		}
+		// The names sort in the opposite order of the files
+		pkgName := fmt.Sprintf("n%02d", 20-i)
+		writeTestRPM(t, name, &testRPM{Name: pkgName, Version: "1.0", Release: "1", Arch: "noarch"})
+		expect = append(expect, pkgName)
+	}
+
+	defer func(v bool) { IgnoreBadRPMs = v }(IgnoreBadRPMs)
+
+	for run := 0; run < 10; run++ {
+		IgnoreBadRPMs = false
+		r, err := NewRepo(dir, &Config{ChecksumCacheType: "none", Workers: 4})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := r.Create(); err == nil || !strings.Contains(err.Error(), "getPackage: /p04.rpm:") {
+			t.Fatalf("run %d: got error %v, expected one for /p04.rpm", run, err)
+		}
+		if _, err := os.Stat(dir + "/" + repoMDXML); !os.IsNotExist(err) {
+			t.Fatalf("run %d: repomd.xml written despite the error", run)
+		}
+
+		IgnoreBadRPMs = true
+		r, err = NewRepo(dir, &Config{ChecksumCacheType: "none", Workers: 4})
+		if err != nil {
+			t.Fatal(err)
+		}
+		summary, err := r.Create()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if summary.RPMs != len(expect) {
+			t.Fatalf("run %d: got %d rpms, expected %d", run, summary.RPMs, len(expect))
+		}
+
+		repo, err := OpenRepo(dir)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var got []string
+		for _, pkg := range repo.Packages() {
+			got = append(got, pkg.Name)
+		}
+		if strings.Join(got, ",") != strings.Join(expect, ",") {
+			t.Fatalf("run %d: got order %q, expected %q", run, got, expect)
+		}
+
+		if err := os.RemoveAll(dir + "/" + repoDataDir); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
@@ -29,33 +29,21 @@ func (p *primary) String() string {
 	return string(b)
 }
 
-// writeData returns the metadata and file content of the primary
-func (p *primary) writeData(baseDir, compressAlgo string) (*data, error) {
-	x, err := p.XML()
-	if err != nil {
-		return nil, err
-	}
-	compressed, checksum, suffix, err := compress(x, compressAlgo)
-	if err != nil {
-		return nil, err
-	}
-
-	size := uint64(len(compressed))
-	href := repoDataDir + "/" + checksum.Data + "-primary.xml" + suffix
-	modTime, err := writeFile(baseDir+"/"+href, compressed)
-	if err != nil {
-		return nil, err
-	}
-
-	data := &data{
-		Type:         "primary",
-		Checksum:     checksum,
-		OpenChecksum: p.OpenChecksum,
-		Location:     &location{Href: href},
-		Size:         &size,
-		OpenSize:     &p.OpenSize,
-		Timestamp:    &modTime,
-	}
+// stage encodes the primary into compressed temporary files, one per
+// compression algorithm
+func (p *primary) stage(baseDir string, config *Config) ([]*stagedFile, error) {
+	return stageFiles(baseDir, "primary", "primary.xml", config.chunkedCompressAlgos(), config.CompressLevel, config.ChecksumType, p.encodeChunks)
+}
 
-	return data, nil
+// encodeChunks encodes the primary with one chunk per package
+func (p *primary) encodeChunks(w chunkWriter) error {
+	root := xml.StartElement{Name: xml.Name{Local: "metadata"}, Attr: []xml.Attr{
+		{Name: xml.Name{Local: "xmlns"}, Value: p.Namespace},
+		{Name: xml.Name{Local: "xmlns:rpm"}, Value: p.NamespaceRPM},
+		{Name: xml.Name{Local: "packages"}, Value: p.Count},
+	}}
+
+	return encodeXMLChunks(w, root, len(p.Packages), func(i int) (any, xml.StartElement) {
+		return p.Packages[i], xml.StartElement{Name: xml.Name{Local: "package"}}
+	})
 }
@@ -0,0 +1,581 @@
+package createrepo
+
+import (
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// Repository represents a read-only view of an existing repository as
+// published by its current repomd.xml.
+type Repository struct {
+	dir          string
+	revision     float64
+	packages     []*Package
+	groups       []*Group
+	categories   []*Category
+	environments []*Environment
+	byName       map[string][]*Package
+	byNEVRA      map[string]*Package
+	byProvide    map[string][]*Package
+	byFile       map[string][]*Package
+}
+
+// Package represents a package in a repository. LocationHref is the
+// unescaped path of the RPM relative to LocationBase, or to the
+// repository directory if LocationBase is empty.
+type Package struct {
+	Name          string
+	Arch          string
+	Epoch         int
+	Version       string
+	Release       string
+	Checksum      string
+	ChecksumType  string
+	Summary       string
+	Description   string
+	Packager      string
+	URL           string
+	License       string
+	Vendor        string
+	Group         string
+	BuildHost     string
+	SourceRPM     string
+	FileTime      time.Time
+	BuildTime     time.Time
+	Size          int64
+	InstalledSize int64
+	ArchiveSize   int64
+	LocationHref  string
+	LocationBase  string
+	Provides      []*Dependency
+	Requires      []*Dependency
+	Conflicts     []*Dependency
+	Obsoletes     []*Dependency
+	Suggests      []*Dependency
+	Recommends    []*Dependency
+	Files         []*File
+	Changelog     []*ChangelogEntry
+}
+
+// Dependency represents a dependency of a package, e.g. a provide
+// or a requirement. Flags is one of EQ, LT, LE, GT and GE, or empty
+// if no version is given.
+type Dependency struct {
+	Name    string
+	Flags   string
+	Epoch   string
+	Version string
+	Release string
+	Pre     bool
+}
+
+// File represents a file of a package. Type is dir or ghost for
+// directories and ghost files, and empty for other files.
+type File struct {
+	Path string
+	Type string
+}
+
+// ChangelogEntry represents a changelog entry of a package.
+type ChangelogEntry struct {
+	Author string
+	Date   time.Time
+	Text   string
+}
+
+// Group represents a comps group.
+type Group struct {
+	ID          string
+	Name        string
+	Description string
+	Default     bool
+	UserVisible bool
+	Packages    []*GroupPackage
+}
+
+// GroupPackage represents a package of a comps group. Type is e.g.
+// mandatory, default or optional.
+type GroupPackage struct {
+	Name string
+	Type string
+}
+
+// Category represents a comps category.
+type Category struct {
+	ID           string
+	Name         string
+	Description  string
+	DisplayOrder string
+	Groups       []string
+}
+
+// Environment represents a comps environment.
+type Environment struct {
+	ID           string
+	Name         string
+	Description  string
+	DisplayOrder string
+	Groups       []string
+	Options      []string
+}
+
+// OpenRepo reads the current metadata of the repository in dir, and
+// returns a read-only handle to it.
+func OpenRepo(dir string) (*Repository, error) {
+	dir = filepath.Clean(dir)
+
+	repomd, err := readRepoMDFrom(dir)
+	if err != nil {
+		return nil, fmt.Errorf("repomd: %v", err)
+	}
+	if repomd == nil {
+		return nil, fmt.Errorf("%q has no %s", dir, repoMDXML)
+	}
+
+	return openRepoMD(dir, repomd)
+}
+
+// OpenRepoMD reads the metadata of the named repomd.xml file, and
+// returns a read-only handle to it. The file must be in the repodata
+// directory of the repository.
+func OpenRepoMD(name string) (*Repository, error) {
+	name = filepath.Clean(name)
+	dir := filepath.Dir(filepath.Dir(name))
+
+	content, err := os.ReadFile(name)
+	if err != nil {
+		return nil, err
+	}
+
+	repomd, err := parseRepoMD(content, dir)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", name, err)
+	}
+
+	return openRepoMD(dir, repomd)
+}
+
+// OpenRevision reads the metadata of a revision in the history of the
+// repository in dir, and returns a read-only handle to it. The data
+// files of the revision must still exist.
+func OpenRevision(dir string, rev int64) (*Repository, error) {
+	dir = filepath.Clean(dir)
+
+	hist, err := readHistory(dir)
+	if err != nil {
+		return nil, fmt.Errorf("history: %v", err)
+	}
+	if hist == nil {
+		return nil, fmt.Errorf("no history found in %q", dir)
+	}
+
+	for _, h := range hist.Revisions {
+		if int64(h.Revision) == rev {
+			repomd := &repoMD{baseDir: dir, Revision: h.Revision, Data: h.Data}
+			return openRepoMD(dir, repomd)
+		}
+	}
+
+	return nil, fmt.Errorf("revision %d not found in history", rev)
+}
+
+// openRepoMD returns a read-only handle to the metadata of repomd.
+func openRepoMD(dir string, repomd *repoMD) (*Repository, error) {
+	primaryData := repomd.find("primary")
+	if primaryData == nil {
+		return nil, fmt.Errorf("%q has no primary data", dir)
+	}
+
+	p := &primary{}
+	if err := decodeData(primaryData, dir, p); err != nil {
+		return nil, err
+	}
+
+	lists := make(map[string][]*packageList)
+	if d := repomd.find("filelists"); d != nil {
+		f := &fileLists{}
+		if err := decodeData(d, dir, f); err != nil {
+			return nil, err
+		}
+		for _, pl := range f.Packages {
+			lists[pl.PkgID] = append(lists[pl.PkgID], pl)
+		}
+	}
+
+	others := make(map[string][]*otherPackage)
+	if d := repomd.find("other"); d != nil {
+		o := &other{}
+		if err := decodeData(d, dir, o); err != nil {
+			return nil, err
+		}
+		for _, op := range o.Packages {
+			others[op.PkgID] = append(others[op.PkgID], op)
+		}
+	}
+
+	r := &Repository{
+		dir:       dir,
+		revision:  repomd.Revision,
+		byName:    make(map[string][]*Package),
+		byNEVRA:   make(map[string]*Package),
+		byProvide: make(map[string][]*Package),
+		byFile:    make(map[string][]*Package),
+	}
+
+	for _, rp := range p.Packages {
+		pkg := newPackage(rp)
+
+		// The same RPM may be listed more than once, e.g. through
+		// symbolic links, so the entries are matched in order.
+		if rp.Checksum != nil {
+			if ls := lists[rp.Checksum.Data]; len(ls) > 0 {
+				lists[rp.Checksum.Data] = ls[1:]
+				pkg.Files = nil
+				for _, f := range ls[0].Files {
+					pkg.Files = append(pkg.Files, &File{Path: f.Path, Type: f.Type})
+				}
+			}
+			if ls := others[rp.Checksum.Data]; len(ls) > 0 {
+				others[rp.Checksum.Data] = ls[1:]
+				for _, c := range ls[0].Changelog {
+					pkg.Changelog = append(pkg.Changelog, &ChangelogEntry{Author: c.Author, Date: time.Unix(c.Date, 0), Text: c.Text})
+				}
+			}
+		}
+
+		r.add(pkg)
+	}
+
+	if d := repomd.find("group"); d != nil {
+		c := &comps{}
+		if err := decodeData(d, dir, c); err != nil {
+			return nil, err
+		}
+		r.addComps(c)
+	}
+
+	return r, nil
+}
+
+// newPackage returns a Package of the primary package entry. The
+// files are the ones listed in primary, until replaced by the ones
+// in filelists.
+func newPackage(rp *rpmPackage) *Package {
+	pkg := &Package{
+		Name:        rp.Name,
+		Arch:        rp.Arch,
+		Summary:     rp.Summary,
+		Description: rp.Description,
+		Packager:    rp.Packager,
+		URL:         rp.URL,
+	}
+
+	if rp.Version != nil {
+		pkg.Epoch = rp.Version.Epoch
+		pkg.Version = rp.Version.Version
+		pkg.Release = rp.Version.Release
+	}
+	if rp.Checksum != nil {
+		pkg.Checksum = rp.Checksum.Data
+		pkg.ChecksumType = rp.Checksum.Type
+	}
+	if rp.Time != nil {
+		pkg.FileTime = time.Unix(atoi(rp.Time.File), 0)
+		pkg.BuildTime = time.Unix(atoi(rp.Time.Build), 0)
+	}
+	if rp.Size != nil {
+		pkg.Size = atoi(rp.Size.Package)
+		pkg.InstalledSize = atoi(rp.Size.Installed)
+		pkg.ArchiveSize = atoi(rp.Size.Archive)
+	}
+	if rp.Location != nil {
+		pkg.LocationHref = rp.Location.Href
+		if href, err := url.PathUnescape(rp.Location.Href); err == nil {
+			pkg.LocationHref = href
+		}
+		pkg.LocationBase = rp.Location.Base
+	}
+
+	if f := rp.Format; f != nil {
+		if f.License != nil {
+			pkg.License = f.License.License
+		}
+		if f.Vendor != nil {
+			pkg.Vendor = f.Vendor.Vendor
+		}
+		if f.Group != nil {
+			pkg.Group = f.Group.Group
+		}
+		if f.BuildHost != nil {
+			pkg.BuildHost = f.BuildHost.BuildHost
+		}
+		if f.SourceRPM != nil {
+			pkg.SourceRPM = f.SourceRPM.SourceRPM
+		}
+		pkg.Provides = newDependencies(f.Provides)
+		pkg.Requires = newDependencies(f.Requires)
+		pkg.Conflicts = newDependencies(f.Conflicts)
+		pkg.Obsoletes = newDependencies(f.Obsoletes)
+		pkg.Suggests = newDependencies(f.Suggests)
+		pkg.Recommends = newDependencies(f.Recommends)
+		for _, f := range f.Files {
+			pkg.Files = append(pkg.Files, &File{Path: f.Path})
+		}
+	}
+
+	return pkg
+}
+
+// newDependencies returns the entries as dependencies.
+func newDependencies(entries []*entry) []*Dependency {
+	var ret []*Dependency
+	for _, e := range entries {
+		ret = append(ret, &Dependency{
+			Name:    e.Name,
+			Flags:   e.Flags,
+			Epoch:   e.Epoch,
+			Version: e.Version,
+			Release: e.Release,
+			Pre:     e.Pre == "1" || e.Pre == "true",
+		})
+	}
+
+	return ret
+}
+
+// add adds the package to the repository and its indexes.
+func (r *Repository) add(pkg *Package) {
+	r.packages = append(r.packages, pkg)
+	r.byName[pkg.Name] = append(r.byName[pkg.Name], pkg)
+	if _, ok := r.byNEVRA[pkg.NEVRA()]; !ok {
+		r.byNEVRA[pkg.NEVRA()] = pkg
+	}
+
+	seen := make(map[string]bool)
+	for _, d := range pkg.Provides {
+		if !seen[d.Name] {
+			seen[d.Name] = true
+			r.byProvide[d.Name] = append(r.byProvide[d.Name], pkg)
+		}
+	}
+
+	seen = make(map[string]bool)
+	for _, f := range pkg.Files {
+		if !seen[f.Path] {
+			seen[f.Path] = true
+			r.byFile[f.Path] = append(r.byFile[f.Path], pkg)
+		}
+	}
+}
+
+// addComps adds the groups, categories and environments of c.
+func (r *Repository) addComps(c *comps) {
+	for _, g := range c.Group {
+		group := &Group{
+			ID:          g.ID,
+			Name:        nameOf(g.Name),
+			Description: descriptionOf(g.Description),
+			Default:     g.Default,
+			UserVisible: g.Uservisible,
+		}
+		if g.PackageList != nil {
+			for _, p := range g.PackageList.PackageReqs {
+				group.Packages = append(group.Packages, &GroupPackage{Name: p.PackageReq, Type: p.Type})
+			}
+		}
+		r.groups = append(r.groups, group)
+	}
+
+	for _, c := range c.Category {
+		r.categories = append(r.categories, &Category{
+			ID:           c.ID,
+			Name:         nameOf(c.Name),
+			Description:  descriptionOf(c.Description),
+			DisplayOrder: c.DisplayOrder,
+			Groups:       groupIDs(c.GroupList),
+		})
+	}
+
+	for _, e := range c.Environment {
+		r.environments = append(r.environments, &Environment{
+			ID:           e.ID,
+			Name:         nameOf(e.Name),
+			Description:  descriptionOf(e.Description),
+			DisplayOrder: e.DisplayOrder,
+			Groups:       groupIDs(e.GroupList),
+			Options:      groupIDs(e.OptionList),
+		})
+	}
+}
+
+// nameOf returns the untranslated name.
+func nameOf(names []*nameLang) string {
+	for _, n := range names {
+		if n.Lang == "" {
+			return n.Name
+		}
+	}
+
+	return ""
+}
+
+// descriptionOf returns the untranslated description.
+func descriptionOf(descriptions []*descriptionLang) string {
+	for _, d := range descriptions {
+		if d.Lang == "" {
+			return d.Description
+		}
+	}
+
+	return ""
+}
+
+// groupIDs returns the group IDs of the group list.
+func groupIDs(l *compsGroupList) []string {
+	if l == nil {
+		return nil
+	}
+
+	var ret []string
+	for _, id := range l.GroupID {
+		ret = append(ret, id.GroupIDEntry)
+	}
+
+	return ret
+}
+
+// Dir returns the repository directory.
+func (r *Repository) Dir() string {
+	return r.dir
+}
+
+// Revision returns the revision of the repository metadata.
+func (r *Repository) Revision() int64 {
+	return int64(r.revision)
+}
+
+// Len returns the number of packages.
+func (r *Repository) Len() int {
+	return len(r.packages)
+}
+
+// Packages returns all packages in the order of primary.
+func (r *Repository) Packages() []*Package {
+	return append([]*Package(nil), r.packages...)
+}
+
+// Each calls fn for every package in the order of primary, until fn
+// returns false.
+func (r *Repository) Each(fn func(*Package) bool) {
+	for _, pkg := range r.packages {
+		if !fn(pkg) {
+			return
+		}
+	}
+}
+
+// PackagesByName returns the packages with the given name.
+func (r *Repository) PackagesByName(name string) []*Package {
+	return append([]*Package(nil), r.byName[name]...)
+}
+
+// PackageByNEVRA returns the package with the given
+// name-[epoch:]version-release.arch, or nil if not found. A zero
+// epoch may be omitted.
+func (r *Repository) PackageByNEVRA(nevra string) *Package {
+	if pkg, ok := r.byNEVRA[nevra]; ok {
+		return pkg
+	}
+
+	return r.byNEVRA[strings.Replace(nevra, "-0:", "-", 1)]
+}
+
+// WhatProvides returns the packages providing the named
+// capability. Paths are also matched against the package files.
+func (r *Repository) WhatProvides(name string) []*Package {
+	ret := append([]*Package(nil), r.byProvide[name]...)
+	if strings.HasPrefix(name, "/") {
+		for _, pkg := range r.byFile[name] {
+			if !containsPackage(ret, pkg) {
+				ret = append(ret, pkg)
+			}
+		}
+	}
+
+	return ret
+}
+
+// PackagesByFile returns the packages owning the file with the given
+// path.
+func (r *Repository) PackagesByFile(path string) []*Package {
+	return append([]*Package(nil), r.byFile[path]...)
+}
+
+// Groups returns the comps groups.
+func (r *Repository) Groups() []*Group {
+	return append([]*Group(nil), r.groups...)
+}
+
+// Categories returns the comps categories.
+func (r *Repository) Categories() []*Category {
+	return append([]*Category(nil), r.categories...)
+}
+
+// Environments returns the comps environments.
+func (r *Repository) Environments() []*Environment {
+	return append([]*Environment(nil), r.environments...)
+}
+
+// containsPackage returns true if pkg is in ls.
+func containsPackage(ls []*Package, pkg *Package) bool {
+	for _, p := range ls {
+		if p == pkg {
+			return true
+		}
+	}
+
+	return false
+}
+
+// EVR returns the [epoch:]version-release of the package. A zero
+// epoch is omitted.
+func (p *Package) EVR() string {
+	if p.Epoch != 0 {
+		return fmt.Sprintf("%d:%s-%s", p.Epoch, p.Version, p.Release)
+	}
+
+	return p.Version + "-" + p.Release
+}
+
+// NEVRA returns the name-[epoch:]version-release.arch of the
+// package. A zero epoch is omitted.
+func (p *Package) NEVRA() string {
+	return p.Name + "-" + p.EVR() + "." + p.Arch
+}
+
+func (p *Package) String() string {
+	return p.NEVRA()
+}
+
+// String returns the dependency as in a spec file, e.g.
+// "name >= 1:2.0-1".
+func (d *Dependency) String() string {
+	op := map[string]string{"EQ": "=", "LT": "<", "LE": "<=", "GT": ">", "GE": ">="}[d.Flags]
+	if op == "" {
+		return d.Name
+	}
+
+	evr := d.Version
+	if d.Epoch != "" && d.Epoch != "0" {
+		evr = d.Epoch + ":" + evr
+	}
+	if d.Release != "" {
+		evr += "-" + d.Release
+	}
+
+	return d.Name + " " + op + " " + evr
+}
@@ -0,0 +1,184 @@
+package createrepo
+
+import (
+	"os"
+	"strings"
+	"testing"
+)
+
+// testComps is the comps file of the repository made by writeTestRepo.
+const testComps = `<?xml version="1.0" encoding="UTF-8"?>
+<comps>
+  <group>
+    <id>core</id>
+    <name>Core</name>
+    <name xml:lang="nb">Kjerne</name>
+    <description>The core packages</description>
+    <default>true</default>
+    <uservisible>false</uservisible>
+    <packagelist>
+      <packagereq type="mandatory">a</packagereq>
+      <packagereq type="optional">b</packagereq>
+    </packagelist>
+  </group>
+  <category>
+    <id>base</id>
+    <name>Base</name>
+    <display_order>10</display_order>
+    <grouplist>
+      <groupid>core</groupid>
+    </grouplist>
+  </category>
+  <environment>
+    <id>minimal</id>
+    <name>Minimal</name>
+    <display_order>5</display_order>
+    <grouplist>
+      <groupid>core</groupid>
+    </grouplist>
+    <optionlist>
+      <groupid>extra</groupid>
+    </optionlist>
+  </environment>
+</comps>
+`
+
+// writeTestRepo creates a repository in a temporary directory with two
+// RPMs and a comps file, and returns the directory.
+func writeTestRepo(t *testing.T) string {
+	t.Helper()
+
+	dir := t.TempDir()
+	if err := os.Mkdir(dir+"/sub", 0755); err != nil {
+		t.Fatal(err)
+	}
+	writeTestRPM(t, dir+"/sub/a+b~1.rpm", &testRPM{Name: "a", Version: "1.0", Release: "1", Arch: "x86_64",
+		Summary:  "Package a",
+		Provides: []string{"a = 1.0-1", "liba.so.1()(64bit)"},
+		Requires: []string{"/bin/sh"},
+		Files:    []string{"/usr/bin/a", "/usr/lib64/liba.so.1"},
+		Changelog: []*testChangelog{
+			{Time: 200, Author: "B", Text: "- second"},
+			{Time: 100, Author: "A", Text: "- first"},
+		},
+	})
+	writeTestRPM(t, dir+"/b.rpm", &testRPM{Name: "b", Epoch: 1, Version: "2.0", Release: "1", Arch: "noarch",
+		Provides: []string{"b = 1:2.0-1"},
+		Requires: []string{"a >= 1.0", "liba.so.1()(64bit)"},
+		Files:    []string{"/usr/bin/a", "/usr/share/b/README"},
+	})
+
+	comps := t.TempDir() + "/comps.xml"
+	if err := os.WriteFile(comps, []byte(testComps), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	r, err := NewRepo(dir, &Config{ChecksumCacheType: "none", CompsFile: comps})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := r.Create(); err != nil {
+		t.Fatal(err)
+	}
+
+	return dir
+}
+
+// nevras returns the NEVRAs of the packages joined by a comma.
+func nevras(ls []*Package) string {
+	var ret []string
+	for _, pkg := range ls {
+		ret = append(ret, pkg.NEVRA())
+	}
+
+	return strings.Join(ret, ",")
+}
+
+func TestOpenRepo(t *testing.T) {
+	dir := writeTestRepo(t)
+
+	for _, open := range []func() (*Repository, error){
+		func() (*Repository, error) { return OpenRepo(dir) },
+		func() (*Repository, error) { return OpenRepoMD(dir + "/repodata/repomd.xml") },
+	} {
+		repo, err := open()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if repo.Dir() != dir || repo.Len() != 2 || repo.Revision() == 0 {
+			t.Fatalf("got dir %q with %d packages at revision %d, expected %q with 2", repo.Dir(), repo.Len(), repo.Revision(), dir)
+		}
+
+		a := repo.PackageByNEVRA("a-0:1.0-1.x86_64")
+		if a == nil || a != repo.PackageByNEVRA("a-1.0-1.x86_64") {
+			t.Fatalf("package a not found by its NEVRA with and without epoch")
+		}
+		if a.LocationHref != "sub/a+b~1.rpm" || a.Summary != "Package a" || a.ChecksumType != "sha256" {
+			t.Fatalf("got href %q, summary %q and checksum type %q", a.LocationHref, a.Summary, a.ChecksumType)
+		}
+		if got := len(a.Files); got != 2 {
+			t.Fatalf("got %d files of a, expected 2", got)
+		}
+		if len(a.Changelog) != 2 || a.Changelog[0].Text != "- first" || a.Changelog[1].Date.Unix() != 200 {
+			t.Fatalf("got changelog %v, expected the two entries in order", a.Changelog)
+		}
+		if got := len(a.Requires); got != 1 || a.Requires[0].String() != "/bin/sh" {
+			t.Fatalf("got requires %v of a, expected /bin/sh", a.Requires)
+		}
+
+		b := repo.PackageByNEVRA("b-1:2.0-1.noarch")
+		if b == nil || b.EVR() != "1:2.0-1" {
+			t.Fatalf("package b not found by its NEVRA")
+		}
+		if got := b.Requires[0].String(); got != "a >= 1.0" {
+			t.Fatalf("got requirement %q, expected a >= 1.0", got)
+		}
+
+		tests := []struct {
+			Got    []*Package
+			Expect string
+		}{
+			{repo.Packages(), "b-1:2.0-1.noarch,a-1.0-1.x86_64"},
+			{repo.PackagesByName("b"), "b-1:2.0-1.noarch"},
+			{repo.PackagesByName("c"), ""},
+			{repo.WhatProvides("liba.so.1()(64bit)"), "a-1.0-1.x86_64"},
+			{repo.WhatProvides("/usr/bin/a"), "b-1:2.0-1.noarch,a-1.0-1.x86_64"},
+			{repo.WhatProvides("/usr/share/b"), ""},
+			{repo.PackagesByFile("/usr/share/b/README"), "b-1:2.0-1.noarch"},
+			{repo.PackagesByFile("/usr/lib64/liba.so.1"), "a-1.0-1.x86_64"},
+		}
+		for i, test := range tests {
+			if got := nevras(test.Got); got != test.Expect {
+				t.Fatalf("test %d: got %q, expected %q", i, got, test.Expect)
+			}
+		}
+
+		groups := repo.Groups()
+		if len(groups) != 1 {
+			t.Fatalf("got %d groups, expected 1", len(groups))
+		}
+		g := groups[0]
+		if g.ID != "core" || g.Name != "Core" || g.Description != "The core packages" || !g.Default || g.UserVisible ||
+			len(g.Packages) != 2 || g.Packages[0].Name != "a" || g.Packages[0].Type != "mandatory" || g.Packages[1].Type != "optional" {
+			t.Fatalf("unexpected group %+v", g)
+		}
+
+		categories := repo.Categories()
+		if len(categories) != 1 || categories[0].ID != "base" || categories[0].Name != "Base" ||
+			categories[0].DisplayOrder != "10" || strings.Join(categories[0].Groups, ",") != "core" {
+			t.Fatalf("unexpected categories %+v", categories)
+		}
+
+		environments := repo.Environments()
+		if len(environments) != 1 || environments[0].ID != "minimal" || environments[0].DisplayOrder != "5" ||
+			strings.Join(environments[0].Groups, ",") != "core" || strings.Join(environments[0].Options, ",") != "extra" {
+			t.Fatalf("unexpected environments %+v", environments)
+		}
+	}
+}
+
+func TestOpenRepoMissing(t *testing.T) {
+	if _, err := OpenRepo(t.TempDir()); err == nil {
+		t.Fatalf("got no error for a directory without repomd.xml")
+	}
+}
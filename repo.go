@@ -3,8 +3,12 @@ package createrepo
 
 import (
 	"fmt"
+	"github.com/bmatcuk/doublestar/v4"
+	"net/url"
 	"os"
 	"path/filepath"
+	"strconv"
+	"strings"
 )
 
 const (
@@ -18,6 +22,10 @@ const (
 	// repomd.xml entries for clean ups.
 	historyXML = repoDataDir + "/.history.xml"
 
+	// checksumsXML is the name of the file caching package
+	// checksums when extended attributes can't be used.
+	checksumsXML = repoDataDir + "/.checksums.xml"
+
 	// configYAML is the name of the configuration file. If the
 	// file doesn't exists, a new will be created.
 	configYAML = repoDataDir + "/.config.yaml"
@@ -25,20 +33,43 @@ const (
 
 // Summary represents the Create summary.
 type Summary struct {
-	Dir      string
-	RPMs     int
-	Updated  bool
-	Expunged int
+	Dir               string
+	RPMs              int
+	Updated           bool
+	Expunged          int
+	ExpungedRevisions []int64
+	KeptRevisions     []int64
+	Pruned            []string
+	Garbage           []string
 }
 
 func (s *Summary) String() string {
-	return fmt.Sprintf("repo:%s rpms:%d expunged:%d repomd:%t", s.Dir, s.RPMs, s.Expunged, s.Updated)
+	return fmt.Sprintf("repo:%s rpms:%d expunged:%d%s kept:%d%s pruned:%d repomd:%t", s.Dir, s.RPMs,
+		s.Expunged, revisionList(s.ExpungedRevisions), len(s.KeptRevisions), revisionList(s.KeptRevisions),
+		len(s.Pruned), s.Updated)
+}
+
+// revisionList returns the revisions as a comma separated list in
+// parentheses, or an empty string if there are none.
+func revisionList(revs []int64) string {
+	if len(revs) == 0 {
+		return ""
+	}
+
+	ls := make([]string, len(revs))
+	for i, rev := range revs {
+		ls[i] = strconv.FormatInt(rev, 10)
+	}
+
+	return "(" + strings.Join(ls, ",") + ")"
 }
 
 // Repo represents the repo handler.
 type Repo struct {
-	baseDir string
-	config  *Config
+	baseDir   string
+	outputDir string
+	config    *Config
+	cache     ChecksumCache
 }
 
 // NewRepo returns a new repo handler. The directory is mandatory, and
@@ -46,23 +77,38 @@ type Repo struct {
 // will be read. If the file doesn't exist, a new default Config will
 // be created and saved to disk.
 func NewRepo(dir string, config *Config) (*Repo, error) {
+	return NewRepoWithOutputDir(dir, dir, config)
+}
+
+// NewRepoWithOutputDir returns a new repo handler for the RPMs in dir,
+// with repodata, history and config stored in outputDir rather than in
+// dir. Both directories are mandatory, and must exist. The location
+// of the RPMs in the meta data are relative to dir. Set BaseURL in
+// Config if dir is not served at the same URL as outputDir.
+func NewRepoWithOutputDir(dir, outputDir string, config *Config) (*Repo, error) {
 	if dir == "" {
 		return nil, fmt.Errorf("dir is missing")
 	}
+	if outputDir == "" {
+		return nil, fmt.Errorf("output dir is missing")
+	}
 
 	baseDir := filepath.Clean(dir)
+	outputDir = filepath.Clean(outputDir)
 
-	fi, err := os.Stat(baseDir)
-	if err != nil {
-		return nil, err
-	}
+	for _, d := range []string{baseDir, outputDir} {
+		fi, err := os.Stat(d)
+		if err != nil {
+			return nil, err
+		}
 
-	if !fi.IsDir() {
-		return nil, fmt.Errorf("%q is not a directory", baseDir)
+		if !fi.IsDir() {
+			return nil, fmt.Errorf("%q is not a directory", d)
+		}
 	}
 
 	if config == nil {
-		c, err := readConfig(baseDir)
+		c, err := readConfig(outputDir)
 		if err != nil && !os.IsNotExist(err) {
 			return nil, err
 		}
@@ -83,15 +129,89 @@ func NewRepo(dir string, config *Config) (*Repo, error) {
 			}
 			config.CompsFile = a
 		}
+		if config.PruneDir != "" {
+			a, err := filepath.Abs(config.PruneDir)
+			if err != nil {
+				return nil, err
+			}
+			config.PruneDir = a
+		}
 	}
 
-	switch config.CompressAlgo {
-	case "xz", "gz": // Supported
-	case "":
-		config.CompressAlgo = "xz" // Default
+	if config.BaseURL != "" {
+		u, err := url.Parse(config.BaseURL)
+		if err != nil {
+			return nil, fmt.Errorf("base url: %v", err)
+		}
+		if !u.IsAbs() {
+			return nil, fmt.Errorf("base url %q is not absolute", config.BaseURL)
+		}
+	}
+
+	if config.RetainRevisions < 0 || config.MaxRevisions < 0 {
+		return nil, fmt.Errorf("revision retention must not be negative")
+	}
+	if config.MaxRevisions > 0 && config.RetainRevisions > config.MaxRevisions {
+		return nil, fmt.Errorf("retain revisions %d exceeds max revisions %d", config.RetainRevisions, config.MaxRevisions)
+	}
+
+	if config.GarbageGracePeriod < 0 {
+		return nil, fmt.Errorf("garbage grace period must not be negative")
+	}
+
+	if config.KeepVersions < 0 {
+		return nil, fmt.Errorf("keep versions must not be negative")
+	}
+	switch config.PruneAction {
+	case "", "delete":
+	case "move":
+		if config.PruneDir == "" {
+			return nil, fmt.Errorf("prune action move requires a prune dir")
+		}
 	default:
-		return nil, fmt.Errorf("unsupported compression algorithm: %s", config.CompressAlgo)
+		return nil, fmt.Errorf("unsupported prune action: %s", config.PruneAction)
+	}
+
+	if config.CompressAlgo == "" {
+		config.CompressAlgo = "xz" // Default
+	}
+	for _, pattern := range append(config.Include, config.Exclude...) {
+		if !doublestar.ValidatePattern(pattern) {
+			return nil, fmt.Errorf("invalid pattern: %s", pattern)
+		}
+	}
+
+	if config.ChecksumType == "" {
+		config.ChecksumType = "sha256" // Default
+	}
+	if _, err := newHash(config.ChecksumType); err != nil {
+		return nil, err
+	}
+
+	seen := make(map[string]bool)
+	for _, algo := range config.compressAlgos() {
+		if err := checkCompression(algo, config.CompressLevel); err != nil {
+			return nil, err
+		}
+		if seen[algo] {
+			return nil, fmt.Errorf("compression algorithm %s specified more than once", algo)
+		}
+		seen[algo] = true
+	}
+
+	cache := config.ChecksumCache
+	if cache == nil {
+		switch config.ChecksumCacheType {
+		case "xattr", "":
+			cache = NewXattrChecksumCache(NewFileChecksumCache(outputDir + "/" + checksumsXML))
+		case "file":
+			cache = NewFileChecksumCache(outputDir + "/" + checksumsXML)
+		case "none":
+			cache = NewNoChecksumCache()
+		default:
+			return nil, fmt.Errorf("unsupported checksum cache: %s", config.ChecksumCacheType)
+		}
 	}
 
-	return &Repo{baseDir: baseDir, config: config}, nil
+	return &Repo{baseDir: baseDir, outputDir: outputDir, config: config, cache: cache}, nil
 }
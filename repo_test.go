@@ -0,0 +1,99 @@
+package createrepo
+
+import (
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+)
+
+// listTree returns the paths, sizes and modification times of
+// everything in dir.
+func listTree(t *testing.T, dir string) string {
+	t.Helper()
+
+	var ls []string
+	err := filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		fi, err := d.Info()
+		if err != nil {
+			return err
+		}
+		ls = append(ls, fmt.Sprintf("%s %d %s %s", path, fi.Size(), fi.Mode(), fi.ModTime()))
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	sort.Strings(ls)
+
+	return strings.Join(ls, "\n")
+}
+
+func TestNewRepoWithOutputDir(t *testing.T) {
+	dir := t.TempDir()
+	output := t.TempDir()
+	if err := os.Mkdir(dir+"/sub", 0755); err != nil {
+		t.Fatal(err)
+	}
+	writeTestRPM(t, dir+"/sub/a.rpm", &testRPM{Name: "a", Version: "1.0", Release: "1", Arch: "noarch"})
+	writeTestRPM(t, dir+"/b.rpm", &testRPM{Name: "b", Version: "1.0", Release: "1", Arch: "noarch"})
+	before := listTree(t, dir)
+
+	r, err := NewRepoWithOutputDir(dir, output, &Config{WriteConfig: true, ChecksumCacheType: "file"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	summary, err := r.Create()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !summary.Updated || summary.RPMs != 2 {
+		t.Fatalf("got updated %t with %d rpms, expected true with 2", summary.Updated, summary.RPMs)
+	}
+
+	for _, name := range []string{repoMDXML, historyXML, configYAML, checksumsXML} {
+		if _, err := os.Stat(output + "/" + name); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if after := listTree(t, dir); after != before {
+		t.Fatalf("package dir changed from\n%s\nto\n%s", before, after)
+	}
+
+	repo, err := OpenRepo(output)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var hrefs []string
+	for _, pkg := range repo.Packages() {
+		hrefs = append(hrefs, pkg.LocationHref)
+	}
+	if got := strings.Join(hrefs, ","); got != "b.rpm,sub/a.rpm" {
+		t.Fatalf("got hrefs %q, expected b.rpm,sub/a.rpm", got)
+	}
+
+	// The saved config is read from the output dir
+	r, err = NewRepoWithOutputDir(dir, output, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r.config.ChecksumCacheType != "file" {
+		t.Fatalf("got checksum cache %q from the saved config, expected file", r.config.ChecksumCacheType)
+	}
+	summary, err = r.Create()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if summary.Updated {
+		t.Fatalf("got updated, expected nothing changed")
+	}
+	if after := listTree(t, dir); after != before {
+		t.Fatalf("package dir changed from\n%s\nto\n%s", before, after)
+	}
+}
@@ -2,6 +2,7 @@ package createrepo
 
 import (
 	"encoding/xml"
+	"fmt"
 	"os"
 	"time"
 )
@@ -48,7 +49,13 @@ func (r *repoMD) Write() error {
 // exists. If not, a nill is returned. Upon other errors, the error
 // will be set.
 func (r *Repo) readRepoMD() (*repoMD, error) {
-	content, err := os.ReadFile(r.baseDir + "/" + repoMDXML)
+	return readRepoMDFrom(r.outputDir)
+}
+
+// readRepoMDFrom returns a RepoMD from the repomd.xml of the
+// repository in dir. If it doesn't exist, a nil is returned.
+func readRepoMDFrom(dir string) (*repoMD, error) {
+	content, err := os.ReadFile(dir + "/" + repoMDXML)
 	if err != nil {
 		if os.IsNotExist(err) {
 			return nil, nil
@@ -56,15 +63,41 @@ func (r *Repo) readRepoMD() (*repoMD, error) {
 		return nil, err
 	}
 
-	repomd := &repoMD{baseDir: r.baseDir}
+	return parseRepoMD(content, dir)
+}
+
+// parseRepoMD returns a RepoMD from the content of a repomd.xml of the
+// repository in dir.
+func parseRepoMD(content []byte, dir string) (*repoMD, error) {
+	repomd := &repoMD{baseDir: dir}
 
 	if err := xml.Unmarshal(content, repomd); err != nil {
 		return nil, err
 	}
 
+	for _, d := range repomd.Data {
+		if d.Location == nil {
+			return nil, fmt.Errorf("data %q has no location", d.Type)
+		}
+		if err := checkHref(d.Location.Href); err != nil {
+			return nil, err
+		}
+	}
+
 	return repomd, nil
 }
 
+// find returns the data of the given type, or nil if not present.
+func (r *repoMD) find(dataType string) *data {
+	for _, d := range r.Data {
+		if d.Type == dataType {
+			return d
+		}
+	}
+
+	return nil
+}
+
 // newRepoMD returns a new *RepoMD, the named file will be used when
 // writing to disk.
 func newRepoMD(baseDir string) *repoMD {
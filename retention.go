@@ -0,0 +1,118 @@
+package createrepo
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"syscall"
+)
+
+// keepNewest returns whether each package is among the n newest
+// versions of its name and arch. Packages of the same version, e.g.
+// the same RPM in different directories, count as one version.
+func keepNewest(packages []*rpmPackage, n int) []bool {
+	keep := make([]bool, len(packages))
+
+	groups := make(map[string][]int)
+	for i, pkg := range packages {
+		key := pkg.Name + "." + pkg.Arch
+		groups[key] = append(groups[key], i)
+	}
+
+	for _, group := range groups {
+		sort.SliceStable(group, func(a, b int) bool {
+			return packageEVR(packages[group[a]]).Compare(packageEVR(packages[group[b]])) > 0
+		})
+
+		versions := 0
+		for i, idx := range group {
+			if i == 0 || packageEVR(packages[group[i-1]]).Compare(packageEVR(packages[idx])) != 0 {
+				versions++
+			}
+			keep[idx] = versions <= n
+		}
+	}
+
+	return keep
+}
+
+// packageEVR returns the EVR of the package.
+func packageEVR(pkg *rpmPackage) EVR {
+	if pkg.Version == nil {
+		return EVR{}
+	}
+
+	return EVR{
+		Epoch:   strconv.Itoa(pkg.Version.Epoch),
+		Version: pkg.Version.Version,
+		Release: pkg.Version.Release,
+	}
+}
+
+// prune deletes or moves the named RPMs according to PruneAction.
+func (r *Repo) prune(names []string) error {
+	for _, name := range names {
+		path := r.baseDir + "/" + name
+		switch r.config.PruneAction {
+		case "delete":
+			if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+				return err
+			}
+		case "move":
+			dst := r.config.PruneDir + "/" + name
+			if err := os.MkdirAll(filepath.Dir(dst), 0777); err != nil {
+				return err
+			}
+			if err := moveFile(path, dst); err != nil && !os.IsNotExist(err) {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// moveFile renames src to dst. If they are on different file systems,
+// src is copied to dst and then removed.
+func moveFile(src, dst string) error {
+	err := os.Rename(src, dst)
+	if err == nil || !errors.Is(err, syscall.EXDEV) {
+		return err
+	}
+
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	fi, err := in.Stat()
+	if err != nil {
+		return err
+	}
+
+	out, err := os.OpenFile(dst, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, fi.Mode().Perm())
+	if err != nil {
+		return err
+	}
+
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		os.Remove(dst)
+		return fmt.Errorf("copy %s: %v", src, err)
+	}
+	if err := out.Close(); err != nil {
+		os.Remove(dst)
+		return err
+	}
+
+	if err := os.Chtimes(dst, fi.ModTime(), fi.ModTime()); err != nil {
+		return err
+	}
+
+	return os.Remove(src)
+}
@@ -0,0 +1,118 @@
+package createrepo
+
+import (
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestKeepNewest(t *testing.T) {
+	pkg := func(name, arch string, epoch int, ver, rel string) *rpmPackage {
+		return &rpmPackage{Name: name, Arch: arch, Version: &version{Epoch: epoch, Version: ver, Release: rel}}
+	}
+	packages := []*rpmPackage{
+		pkg("a", "x86_64", 0, "2.0", "1"),
+		pkg("a", "x86_64", 1, "1.0", "1"),
+		pkg("a", "x86_64", 0, "2.0~rc1", "1"),
+		pkg("a", "x86_64", 0, "2.0", "1"),
+		pkg("a", "x86_64", 0, "1.9", "1"),
+		pkg("a", "noarch", 0, "0.1", "1"),
+		pkg("b", "x86_64", 0, "1.0", "2~beta"),
+		pkg("b", "x86_64", 0, "1.0", "2"),
+	}
+
+	tests := []struct {
+		N      int
+		Expect string
+	}{
+		{1, "-+---+-+"},
+		{2, "++-+-+++"},
+		{3, "++++-+++"},
+		{4, "++++++++"},
+	}
+
+	for _, test := range tests {
+		var got string
+		for _, keep := range keepNewest(packages, test.N) {
+			if keep {
+				got += "+"
+			} else {
+				got += "-"
+			}
+		}
+		if got != test.Expect {
+			t.Fatalf("keepNewest(%d): got %s, expected %s", test.N, got, test.Expect)
+		}
+	}
+}
+
+// listFiles returns the RPMs in dir, relative to dir and sorted.
+func listFiles(t *testing.T, dir string) string {
+	t.Helper()
+
+	ls, err := getRPMFiles(dir, &Config{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return strings.Join(ls, ", ")
+}
+
+func TestPrune(t *testing.T) {
+	outside := t.TempDir()
+
+	tests := []struct {
+		Action   string
+		PruneDir string
+		// Pruned is the RPMs pruned by the second run
+		Pruned string
+		Expect string
+		Moved  string
+	}{
+		{"", "", "x86_64/a-1.0.rpm, x86_64/a-2.0.rpm", "/x86_64/a-1.0.rpm, /x86_64/a-2.0.rpm, /x86_64/a-3.0.rpm", ""},
+		{"delete", "", "", "/x86_64/a-3.0.rpm", ""},
+		{"move", outside, "", "/x86_64/a-3.0.rpm", "/x86_64/a-1.0.rpm, /x86_64/a-2.0.rpm"},
+		{"move", "pruned", "", "/pruned/x86_64/a-1.0.rpm, /pruned/x86_64/a-2.0.rpm, /x86_64/a-3.0.rpm", "/x86_64/a-1.0.rpm, /x86_64/a-2.0.rpm"},
+	}
+
+	for i, test := range tests {
+		dir := t.TempDir()
+		if err := os.Mkdir(dir+"/x86_64", 0755); err != nil {
+			t.Fatal(err)
+		}
+		for _, v := range []string{"1.0", "2.0", "3.0"} {
+			writeTestRPM(t, dir+"/x86_64/a-"+v+".rpm", &testRPM{Name: "a", Version: v, Release: "1", Arch: "x86_64"})
+		}
+		pruneDir := test.PruneDir
+		if pruneDir == "pruned" {
+			pruneDir = dir + "/pruned"
+		}
+
+		for run, pruned := range []string{"x86_64/a-1.0.rpm, x86_64/a-2.0.rpm", test.Pruned} {
+			r, err := NewRepo(dir, &Config{ChecksumCacheType: "none", KeepVersions: 1, PruneAction: test.Action, PruneDir: pruneDir})
+			if err != nil {
+				t.Fatal(err)
+			}
+			summary, err := r.Create()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := strings.Join(summary.Pruned, ", "); got != pruned {
+				t.Fatalf("test %d, run %d: got pruned %q, expected %q", i, run, got, pruned)
+			}
+			if summary.RPMs != 1 {
+				t.Fatalf("test %d, run %d: got %d rpms, expected 1", i, run, summary.RPMs)
+			}
+		}
+
+		if got := listFiles(t, dir); got != test.Expect {
+			t.Fatalf("test %d: got files %q, expected %q", i, got, test.Expect)
+		}
+		if pruneDir != "" {
+			if got := listFiles(t, pruneDir); got != test.Moved {
+				t.Fatalf("test %d: got moved files %q, expected %q", i, got, test.Moved)
+			}
+			os.RemoveAll(pruneDir + "/x86_64")
+		}
+	}
+}
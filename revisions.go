@@ -0,0 +1,232 @@
+package createrepo
+
+import (
+	"fmt"
+	"os"
+	"os/user"
+	"sort"
+)
+
+// Revision represents a revision of the repository metadata in
+// history.
+type Revision struct {
+	// Revision is the revision of repomd.xml, the time of
+	// publishing in seconds since the epoch.
+	Revision int64
+
+	// Current is true for the revision currently published.
+	Current bool
+
+	// Obsoleted is the time in seconds since the epoch when the
+	// revision was replaced, or zero if not (yet) known.
+	Obsoleted int64
+
+	// Rollback is the revision rolled back to, if any.
+	Rollback int64
+
+	// Packages is the number of packages.
+	Packages int
+
+	// PackageDigest is a checksum of the set of package checksums,
+	// as "type:digest".
+	PackageDigest string
+
+	// Added, Removed and Updated list the packages changed since
+	// the previous revision, as name-[epoch:]version-release.arch.
+	Added   []string
+	Removed []string
+	Updated []*RevisionUpdate
+
+	// Message is the message given in Config when publishing.
+	Message string
+
+	// Host and User tell who published the revision.
+	Host string
+	User string
+}
+
+// RevisionUpdate represents a package updated (or downgraded) from one
+// version to another.
+type RevisionUpdate struct {
+	From string
+	To   string
+}
+
+// revisionUpdate represents an updated package in .history.xml.
+type revisionUpdate struct {
+	From string `xml:"from,attr"`
+	To   string `xml:"to,attr"`
+}
+
+// History returns the revisions in history, oldest first.
+func (r *Repo) History() ([]*Revision, error) {
+	hist, err := readHistory(r.outputDir)
+	if err != nil || hist == nil {
+		return nil, err
+	}
+
+	repomd, err := r.readRepoMD()
+	if err != nil {
+		return nil, fmt.Errorf("repomd: %v", err)
+	}
+
+	revs := append([]*revision(nil), hist.Revisions...)
+	sort.SliceStable(revs, func(i, j int) bool {
+		return revs[i].Revision < revs[j].Revision
+	})
+
+	var ret []*Revision
+	for _, rev := range revs {
+		e := &Revision{
+			Revision:  int64(rev.Revision),
+			Current:   repomd != nil && rev.Revision == repomd.Revision,
+			Obsoleted: rev.Obsoleted,
+			Rollback:  int64(rev.Rollback),
+			Packages:  rev.Packages,
+			Added:     rev.Added,
+			Removed:   rev.Removed,
+			Message:   rev.Message,
+			Host:      rev.Host,
+			User:      rev.User,
+		}
+		if rev.PackageDigest != nil {
+			e.PackageDigest = rev.PackageDigest.Type + ":" + rev.PackageDigest.Data
+		}
+		for _, u := range rev.Updated {
+			e.Updated = append(e.Updated, &RevisionUpdate{From: u.From, To: u.To})
+		}
+		ret = append(ret, e)
+	}
+
+	return ret, nil
+}
+
+// describe records the package set, the changes from the old package
+// set, the message, the publisher and the settings in the
+// revision. If the old package set is unknown, old is nil and no
+// changes are recorded.
+func (rev *revision) describe(packages, old []*rpmPackage, config *Config) {
+	rev.Packages = len(packages)
+	rev.PackageDigest = packageDigest(packages, config.ChecksumType)
+	rev.Message = config.Message
+	rev.Settings = config.fingerprint()
+	rev.Host, _ = os.Hostname()
+	if u, err := user.Current(); err == nil {
+		rev.User = u.Username
+	} else {
+		rev.User = os.Getenv("USER")
+	}
+
+	if old != nil {
+		rev.Added, rev.Removed, rev.Updated = packageChanges(old, packages)
+	}
+}
+
+// packageDigest returns a checksum of the sorted package checksums.
+func packageDigest(packages []*rpmPackage, checksumType string) *checksum {
+	var sums []string
+	for _, pkg := range packages {
+		if pkg.Checksum != nil {
+			sums = append(sums, pkg.Checksum.Data)
+		}
+	}
+	sort.Strings(sums)
+
+	h, err := newHash(checksumType)
+	if err != nil {
+		return nil
+	}
+	for _, sum := range sums {
+		h.Write([]byte(sum + "\n"))
+	}
+
+	return newChecksum(checksumType, h)
+}
+
+// packageChanges returns the NEVRAs added and removed from old to cur.
+// A name and arch with a single version removed and a single version
+// added is reported as updated instead.
+func packageChanges(old, cur []*rpmPackage) ([]string, []string, []*revisionUpdate) {
+	oldSet := nevraSet(old)
+	newSet := nevraSet(cur)
+
+	added := make(map[string][]string)
+	removed := make(map[string][]string)
+	for nevra, key := range newSet {
+		if _, ok := oldSet[nevra]; !ok {
+			added[key] = append(added[key], nevra)
+		}
+	}
+	for nevra, key := range oldSet {
+		if _, ok := newSet[nevra]; !ok {
+			removed[key] = append(removed[key], nevra)
+		}
+	}
+
+	var addedList, removedList []string
+	var updated []*revisionUpdate
+	for key, ls := range added {
+		if r := removed[key]; len(ls) == 1 && len(r) == 1 {
+			updated = append(updated, &revisionUpdate{From: r[0], To: ls[0]})
+			delete(removed, key)
+			continue
+		}
+		addedList = append(addedList, ls...)
+	}
+	for _, ls := range removed {
+		removedList = append(removedList, ls...)
+	}
+
+	sort.Strings(addedList)
+	sort.Strings(removedList)
+	sort.Slice(updated, func(i, j int) bool {
+		return updated[i].To < updated[j].To
+	})
+
+	return addedList, removedList, updated
+}
+
+// nevraSet returns the NEVRAs of the packages, mapped to their name
+// and arch.
+func nevraSet(packages []*rpmPackage) map[string]string {
+	ret := make(map[string]string)
+	for _, pkg := range packages {
+		ret[packageNEVRA(pkg)] = pkg.Name + "." + pkg.Arch
+	}
+
+	return ret
+}
+
+// packageNEVRA returns the name-[epoch:]version-release.arch of the
+// package.
+func packageNEVRA(pkg *rpmPackage) string {
+	evr := packageEVR(pkg)
+	if evr.Epoch == "0" {
+		evr.Epoch = ""
+	}
+
+	return pkg.Name + "-" + evr.String() + "." + pkg.Arch
+}
+
+// readPackages returns the packages in primary of the repomd, or nil
+// if it can't be read. Without a repomd, there are no packages.
+func readPackages(repomd *repoMD) []*rpmPackage {
+	if repomd == nil {
+		return []*rpmPackage{}
+	}
+
+	d := repomd.find("primary")
+	if d == nil {
+		return nil
+	}
+
+	p := &primary{}
+	if err := decodeData(d, repomd.baseDir, p); err != nil {
+		return nil
+	}
+	if p.Packages == nil {
+		return []*rpmPackage{}
+	}
+
+	return p.Packages
+}
@@ -0,0 +1,128 @@
+package createrepo
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"testing"
+)
+
+// updatesOf returns the updates as from>to joined by a comma.
+func updatesOf(updates []*RevisionUpdate) string {
+	var ls []string
+	for _, u := range updates {
+		ls = append(ls, u.From+">"+u.To)
+	}
+
+	return strings.Join(ls, ",")
+}
+
+func TestPackageChanges(t *testing.T) {
+	pkg := func(name, arch string, epoch int, ver string) *rpmPackage {
+		return &rpmPackage{Name: name, Arch: arch, Version: &version{Epoch: epoch, Version: ver, Release: "1"}}
+	}
+	old := []*rpmPackage{
+		pkg("a", "x86_64", 0, "1.0"),
+		pkg("b", "noarch", 1, "1.0"),
+		pkg("c", "noarch", 0, "1.0"),
+		pkg("c", "noarch", 0, "2.0"),
+		pkg("d", "x86_64", 0, "1.0"),
+		pkg("f", "noarch", 0, "1.0"),
+	}
+	cur := []*rpmPackage{
+		pkg("a", "x86_64", 0, "2.0"),
+		pkg("a", "i686", 0, "2.0"),
+		pkg("b", "noarch", 2, "0.9"),
+		pkg("c", "noarch", 0, "3.0"),
+		pkg("d", "x86_64", 0, "1.0"),
+		pkg("e", "noarch", 0, "1.0"),
+		pkg("f", "noarch", 0, "0.9"),
+	}
+
+	added, removed, updated := packageChanges(old, cur)
+
+	var updates []*RevisionUpdate
+	for _, u := range updated {
+		updates = append(updates, &RevisionUpdate{From: u.From, To: u.To})
+	}
+	tests := []struct {
+		Got    string
+		Expect string
+	}{
+		{strings.Join(added, ","), "a-2.0-1.i686,c-3.0-1.noarch,e-1.0-1.noarch"},
+		{strings.Join(removed, ","), "c-1.0-1.noarch,c-2.0-1.noarch"},
+		{updatesOf(updates), "a-1.0-1.x86_64>a-2.0-1.x86_64,b-1:1.0-1.noarch>b-2:0.9-1.noarch,f-1.0-1.noarch>f-0.9-1.noarch"},
+	}
+	for i, test := range tests {
+		if test.Got != test.Expect {
+			t.Fatalf("test %d: got %q, expected %q", i, test.Got, test.Expect)
+		}
+	}
+}
+
+func TestHistory(t *testing.T) {
+	dir := t.TempDir()
+	writeTestRPM(t, dir+"/a-1.0.rpm", &testRPM{Name: "a", Version: "1.0", Release: "1", Arch: "noarch"})
+	writeTestRPM(t, dir+"/b.rpm", &testRPM{Name: "b", Epoch: 1, Version: "1.0", Release: "1", Arch: "noarch"})
+
+	config := &Config{ChecksumCacheType: "none", ExpungeOldMetadata: 3600, Message: "first"}
+	r, err := NewRepo(dir, config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := r.Create(); err != nil {
+		t.Fatal(err)
+	}
+	first, err := r.readRepoMD()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := os.Remove(dir + "/a-1.0.rpm"); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Remove(dir + "/b.rpm"); err != nil {
+		t.Fatal(err)
+	}
+	writeTestRPM(t, dir+"/a-2.0.rpm", &testRPM{Name: "a", Version: "2.0", Release: "1", Arch: "noarch"})
+	writeTestRPM(t, dir+"/c.rpm", &testRPM{Name: "c", Version: "1.0", Release: "1", Arch: "noarch"})
+	config.Message = "second"
+	if _, err := r.Create(); err != nil {
+		t.Fatal(err)
+	}
+
+	config.Message = "back"
+	if err := r.Rollback(int64(first.Revision)); err != nil {
+		t.Fatal(err)
+	}
+
+	// A revision in history not published
+	hist, err := readHistory(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	hist.Revisions = append(hist.Revisions, &revision{Revision: first.Revision + 100})
+	if err := hist.write(); err != nil {
+		t.Fatal(err)
+	}
+
+	revisions, err := r.History()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	for _, rev := range revisions {
+		got = append(got, fmt.Sprintf("current:%t rollback:%t packages:%d added:%s removed:%s updated:%s message:%s",
+			rev.Current, rev.Rollback == int64(first.Revision), rev.Packages,
+			strings.Join(rev.Added, ","), strings.Join(rev.Removed, ","), updatesOf(rev.Updated), rev.Message))
+	}
+	expect := []string{
+		"current:false rollback:false packages:2 added:a-1.0-1.noarch,b-1:1.0-1.noarch removed: updated: message:first",
+		"current:false rollback:false packages:2 added:c-1.0-1.noarch removed:b-1:1.0-1.noarch updated:a-1.0-1.noarch>a-2.0-1.noarch message:second",
+		"current:true rollback:true packages:2 added:b-1:1.0-1.noarch removed:c-1.0-1.noarch updated:a-2.0-1.noarch>a-1.0-1.noarch message:back",
+		"current:false rollback:false packages:0 added: removed: updated: message:",
+	}
+	if strings.Join(got, "\n") != strings.Join(expect, "\n") {
+		t.Fatalf("got\n%s\nexpected\n%s", strings.Join(got, "\n"), strings.Join(expect, "\n"))
+	}
+}
@@ -0,0 +1,102 @@
+package createrepo
+
+import (
+	"fmt"
+	"os"
+)
+
+// Rollback republishes the data files of a previous revision in
+// history as a new revision. All the data files of the revision must
+// still exist, with their recorded checksums. The files are protected
+// from cleanup for as long as the rollback is the current
+// revision. Note that the next Create publishes the RPMs currently in
+// the repository again, if they differ.
+func (r *Repo) Rollback(rev int64) error {
+	hist, err := readHistory(r.outputDir)
+	if err != nil {
+		return err
+	}
+	if hist == nil {
+		return fmt.Errorf("no history found in %q", r.outputDir)
+	}
+
+	var target, last *revision
+	for _, h := range hist.Revisions {
+		if int64(h.Revision) == rev {
+			target = h
+		}
+		if last == nil || h.Revision > last.Revision {
+			last = h
+		}
+	}
+	if target == nil {
+		return fmt.Errorf("revision %d not found in history", rev)
+	}
+	if target == last {
+		return fmt.Errorf("revision %d is the current revision", rev)
+	}
+
+	for _, d := range target.Data {
+		if err := d.verify(r.outputDir); err != nil {
+			return fmt.Errorf("revision %d: %v", rev, err)
+		}
+	}
+
+	// The changes are unknown if the current repomd.xml can't be read
+	var old []*rpmPackage
+	if current, err := r.readRepoMD(); err == nil {
+		old = readPackages(current)
+	}
+
+	repomd := newRepoMD(r.outputDir)
+	repomd.Revision = hist.nextRevision(repomd.Revision)
+	repomd.Data = append(repomd.Data, target.Data...)
+	e, err := hist.Append(repomd)
+	if err != nil {
+		return err
+	}
+
+	if err := repomd.Write(); err != nil {
+		return err
+	}
+
+	e.Rollback = target.Revision
+	e.describe(readPackages(repomd), old, r.config)
+	e.Settings = target.Settings
+	if err := hist.write(); err != nil {
+		return err
+	}
+
+	_, _, err = hist.Clean(r.config.ExpungeOldMetadata, r.config.RetainRevisions, r.config.MaxRevisions)
+
+	return err
+}
+
+// verify returns an error if the data file doesn't exist, or doesn't
+// have the recorded checksum.
+func (d *data) verify(baseDir string) error {
+	if d.Location == nil || d.Location.Href == "" {
+		return fmt.Errorf("data %q has no location", d.Type)
+	}
+	if d.Checksum == nil {
+		return fmt.Errorf("data %q has no checksum", d.Type)
+	}
+	if err := checkHref(d.Location.Href); err != nil {
+		return err
+	}
+
+	name := baseDir + "/" + d.Location.Href
+	if _, err := os.Stat(name); err != nil {
+		return err
+	}
+
+	c, err := getChecksumOfFile(name, d.Checksum.Type)
+	if err != nil {
+		return err
+	}
+	if c.Data != d.Checksum.Data {
+		return fmt.Errorf("%s: checksum mismatch", d.Location.Href)
+	}
+
+	return nil
+}
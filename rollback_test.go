@@ -0,0 +1,105 @@
+package createrepo
+
+import (
+	"os"
+	"testing"
+)
+
+func TestRollbackThenCreate(t *testing.T) {
+	dir := t.TempDir()
+	create := func(name string, expunge int64) *repoMD {
+		t.Helper()
+		writeTestRPM(t, dir+"/"+name+".rpm", &testRPM{Name: name, Version: "1.0", Release: "1", Arch: "noarch"})
+		r, err := NewRepo(dir, &Config{ChecksumCacheType: "none", ExpungeOldMetadata: expunge})
+		if err != nil {
+			t.Fatal(err)
+		}
+		summary, err := r.Create()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !summary.Updated {
+			t.Fatalf("%s: got no update", name)
+		}
+		repomd, err := r.readRepoMD()
+		if err != nil {
+			t.Fatal(err)
+		}
+		return repomd
+	}
+
+	// All within the same second
+	first := create("a", 3600)
+	second := create("b", 3600)
+	if second.Revision <= first.Revision {
+		t.Fatalf("got revision %v after %v", second.Revision, first.Revision)
+	}
+
+	r, err := NewRepo(dir, &Config{ChecksumCacheType: "none", ExpungeOldMetadata: 3600})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := r.Rollback(int64(first.Revision)); err != nil {
+		t.Fatal(err)
+	}
+
+	create("c", 3600)
+	repomd := create("d", 0)
+
+	hist, err := readHistory(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, rev := range hist.Revisions {
+		if rev.Revision > repomd.Revision {
+			t.Fatalf("got revision %v in history after the published %v", rev.Revision, repomd.Revision)
+		}
+	}
+	if hist.find(repomd.Revision) == nil {
+		t.Fatalf("published revision %v not in history", repomd.Revision)
+	}
+
+	for _, d := range repomd.Data {
+		if _, err := os.Stat(dir + "/" + d.Location.Href); err != nil {
+			t.Fatalf("published %s: %v", d.Type, err)
+		}
+	}
+	repo, err := OpenRepo(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if repo.Len() != 4 {
+		t.Fatalf("got %d packages, expected 4", repo.Len())
+	}
+}
+
+func TestCleanKeepsPublished(t *testing.T) {
+	dir := t.TempDir()
+	writeTestRPM(t, dir+"/a.rpm", &testRPM{Name: "a", Version: "1.0", Release: "1", Arch: "noarch"})
+	r, err := NewRepo(dir, &Config{ChecksumCacheType: "none", ExpungeOldMetadata: 3600})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := r.Create(); err != nil {
+		t.Fatal(err)
+	}
+	repomd, err := r.readRepoMD()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// A newer revision in history than the one published
+	hist, err := readHistory(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	hist.Revisions = append(hist.Revisions, &revision{Revision: repomd.Revision + 10})
+	if _, expunged, err := hist.Clean(0, 0, 0); err != nil || len(expunged) != 0 {
+		t.Fatalf("got expunged %v and error %v, expected nothing", expunged, err)
+	}
+	for _, d := range repomd.Data {
+		if _, err := os.Stat(dir + "/" + d.Location.Href); err != nil {
+			t.Fatalf("published %s: %v", d.Type, err)
+		}
+	}
+}
@@ -1,12 +1,13 @@
 package createrepo
 
 import (
+	"encoding/xml"
 	"fmt"
+	"github.com/bmatcuk/doublestar/v4"
 	"github.com/cavaliergopher/rpm"
 	"io/fs"
 	"os"
 	"path/filepath"
-	"strconv"
 	"strings"
 )
 
@@ -81,6 +82,7 @@ func (c *checksum) String() string {
 
 // location represents the file path relative to repository directory
 type location struct {
+	Base string `xml:"http://www.w3.org/XML/1998/namespace base,attr,omitempty"`
 	Href string `xml:"href,attr"`
 }
 
@@ -100,6 +102,35 @@ type format struct {
 	Files      []*reqFile `xml:"file,omitempty"`
 }
 
+// formatXML mirrors format with unprefixed element names. The rpm:
+// prefixed names in format can only be used for encoding, as the
+// decoder matches on the local element name.
+type formatXML struct {
+	License    *license   `xml:"license"`
+	Vendor     *vendor    `xml:"vendor"`
+	Group      *group     `xml:"group"`
+	BuildHost  *buildHost `xml:"buildhost"`
+	SourceRPM  *sourceRPM `xml:"sourcerpm"`
+	Provides   []*entry   `xml:"provides>entry,omitempty"`
+	Requires   []*entry   `xml:"requires>entry,omitempty"`
+	Conflicts  []*entry   `xml:"conflicts>entry,omitempty"`
+	Obsoletes  []*entry   `xml:"obsoletes>entry,omitempty"`
+	Suggests   []*entry   `xml:"suggests>entry,omitempty"`
+	Recommends []*entry   `xml:"recommends>entry,omitempty"`
+	Files      []*reqFile `xml:"file,omitempty"`
+}
+
+// UnmarshalXML decodes a format element from primary.xml.
+func (f *format) UnmarshalXML(d *xml.Decoder, start xml.StartElement) error {
+	var x formatXML
+	if err := d.DecodeElement(&x, &start); err != nil {
+		return err
+	}
+	*f = format(x)
+
+	return nil
+}
+
 // license represents package license
 type license struct {
 	License string `xml:",chardata"`
@@ -135,7 +166,7 @@ type entry struct {
 	Pre     string `xml:"pre,attr,omitempty"`
 }
 
-func getPackage(dir, name string) (p *rpmPackage, f *packageList, e error) {
+func getPackage(dir, name string, config *Config, cache ChecksumCache) (p *rpmPackage, f *packageList, o *otherPackage, e error) {
 	defer func() {
 		if r := recover(); r != nil {
 			if err, ok := r.(error); ok {
@@ -145,6 +176,7 @@ func getPackage(dir, name string) (p *rpmPackage, f *packageList, e error) {
 			}
 			p = nil
 			f = nil
+			o = nil
 		}
 	}()
 
@@ -152,24 +184,24 @@ func getPackage(dir, name string) (p *rpmPackage, f *packageList, e error) {
 
 	fi, err := os.Stat(path)
 	if err != nil {
-		return nil, nil, err
+		return nil, nil, nil, err
 	}
 
-	var checksum *checksum
-	if c, ok := getXattrChecksum(path); ok {
-		checksum = c
-	} else if c, err := getChecksumOfFile(path); err == nil {
-		checksum = c
-		if err := setXattrChecksum(path, checksum); err != nil {
-			return nil, nil, err
+	var sum *checksum
+	if digest, ok := cache.Get(path, fi, config.ChecksumType); ok && !config.Rehash {
+		sum = &checksum{Type: config.ChecksumType, PkgID: "YES", Data: digest}
+	} else if c, err := getChecksumOfFile(path, config.ChecksumType); err == nil {
+		sum = c
+		if err := cache.Set(path, fi, sum.Type, sum.Data); err != nil {
+			return nil, nil, nil, err
 		}
 	} else {
-		return nil, nil, err
+		return nil, nil, nil, err
 	}
 
 	pkg, err := rpm.Open(path)
 	if err != nil {
-		return nil, nil, fmt.Errorf("%s: %v", path, err)
+		return nil, nil, nil, fmt.Errorf("%s: %v", path, err)
 	}
 
 	group := &group{Group: "Unspecified"}
@@ -217,8 +249,8 @@ func getPackage(dir, name string) (p *rpmPackage, f *packageList, e error) {
 			File:  fmt.Sprintf("%d", fi.ModTime().Unix()),
 			Build: fmt.Sprintf("%d", pkg.BuildTime().Unix()),
 		},
-		Checksum: checksum,
-		Location: &location{Href: name},
+		Checksum: sum,
+		Location: &location{Href: escapeHref(strings.TrimPrefix(name, "/"))},
 		Size: &size{
 			Package:   fmt.Sprintf("%d", fi.Size()),
 			Installed: fmt.Sprintf("%d", pkg.Size()),
@@ -243,7 +275,7 @@ func getPackage(dir, name string) (p *rpmPackage, f *packageList, e error) {
 	f = &packageList{
 		Name:  pkg.Name(),
 		Arch:  pkg.Architecture(),
-		PkgID: checksum.Data,
+		PkgID: sum.Data,
 		Version: &version{
 			Epoch:   pkg.Epoch(),
 			Version: pkg.Version(),
@@ -252,7 +284,51 @@ func getPackage(dir, name string) (p *rpmPackage, f *packageList, e error) {
 		Files: files,
 	}
 
-	return p, f, nil
+	o = &otherPackage{
+		Name:  pkg.Name(),
+		Arch:  pkg.Architecture(),
+		PkgID: sum.Data,
+		Version: &version{
+			Epoch:   pkg.Epoch(),
+			Version: pkg.Version(),
+			Release: pkg.Release(),
+		},
+		Changelog: getChangelog(pkg, config.ChangelogLimit),
+	}
+
+	return p, f, o, nil
+}
+
+// getChangelog returns the changelog entries of the package in
+// chronological order. The RPM header stores the newest entry
+// first. If limit is greater than zero, only the newest limit entries
+// are returned.
+func getChangelog(pkg *rpm.Package, limit int) []*changelog {
+	times := pkg.Header.GetTag(1080).Int64Slice()
+	names := pkg.Header.GetTag(1081).StringSlice()
+	texts := pkg.Header.GetTag(1082).StringSlice()
+
+	n := len(times)
+	if len(names) < n {
+		n = len(names)
+	}
+	if len(texts) < n {
+		n = len(texts)
+	}
+	if limit > 0 && limit < n {
+		n = limit
+	}
+
+	ret := make([]*changelog, 0, n)
+	for i := n - 1; i >= 0; i-- {
+		ret = append(ret, &changelog{
+			Author: names[i],
+			Date:   times[i],
+			Text:   texts[i],
+		})
+	}
+
+	return ret
 }
 
 func getDependencies(deps []rpm.Dependency, provides map[entry]bool) ([]*entry, map[entry]bool) {
@@ -399,7 +475,7 @@ func compareLibC(c1, c2 string) int {
 	first = first[6:]
 	second = second[6:]
 
-	c := rpmcmp(first, second)
+	c := rpmvercmp(first, second)
 	if c == -1 {
 		c = 2
 	}
@@ -407,102 +483,6 @@ func compareLibC(c1, c2 string) int {
 	return c
 }
 
-func rpmcmp(s1, s2 string) int {
-	if s1 == s2 {
-		return 0
-	}
-
-	// Split strings into number/alpha groups
-	s := [2][]string{}
-	for i, st := range []string{s1, s2} {
-		buf := ""
-		for _, c := range st {
-			if buf == "" {
-				if (c >= 'A' && c <= 'Z') || (c >= 'a' && c <= 'z') || (c >= '0' && c <= '9') {
-					buf = buf + string(c)
-				} else {
-					s[i] = append(s[i], buf)
-				}
-			} else if buf[0] >= '0' && buf[0] <= '9' {
-				if c >= '0' && c <= '9' {
-					buf = buf + string(c)
-				} else if (c >= 'A' && c <= 'Z') || (c >= 'a' && c <= 'z') {
-					s[i] = append(s[i], buf)
-					buf = string(c)
-				} else {
-					s[i] = append(s[i], buf)
-					buf = ""
-				}
-			} else if (buf[0] >= 'A' && buf[0] <= 'Z') || (buf[0] >= 'a' && buf[0] <= 'z') {
-				if (c >= 'A' && c <= 'Z') || (c >= 'a' && c <= 'z') {
-					buf = buf + string(c)
-				} else if c >= '0' && c <= '9' {
-					s[i] = append(s[i], buf)
-					buf = string(c)
-				} else {
-					s[i] = append(s[i], buf)
-					buf = ""
-				}
-			} else {
-				s[i] = append(s[i], buf)
-				buf = ""
-			}
-		}
-		if buf != "" {
-			s[i] = append(s[i], buf)
-		}
-	}
-
-	minv := 0
-	if len(s[0]) < len(s[1]) {
-		minv = len(s[0])
-	} else {
-		minv = len(s[1])
-	}
-
-	for i := 0; i < minv; i++ {
-		l, r := s[0][i], s[1][i]
-		if l == r {
-			continue
-		}
-		if l == "" {
-			return 1
-		}
-		if r == "" {
-			return -1
-		}
-		if l[0] >= '0' && l[0] <= '9' && r[0] >= '0' && r[0] <= '9' {
-			li, _ := strconv.ParseUint(l, 10, 64)
-			ri, _ := strconv.ParseUint(r, 10, 64)
-			if li < ri {
-				return -1
-			} else if li > ri {
-				return 1
-			}
-		} else if ((l[0] >= 'A' && l[0] <= 'Z') || (l[0] >= 'a' && l[0] <= 'z')) &&
-			((r[0] >= 'A' && r[0] <= 'Z') || (r[0] >= 'a' && r[0] <= 'z')) {
-			if l < r {
-				return -1
-			} else if l > r {
-				return 1
-			}
-		} else if ((l[0] >= 'A' && l[0] <= 'Z') || (l[0] >= 'a' && l[0] <= 'z')) &&
-			(r[0] >= '0' && r[0] <= '9') {
-			return -1
-		} else {
-			return 1
-		}
-	}
-
-	if len(s[0]) == len(s[1]) {
-		return 0
-	} else if len(s[0]) < len(s[1]) {
-		return -1
-	}
-
-	return 1
-}
-
 func readParenthesis(s string) ([]string, bool) {
 	var in bool
 	var group string
@@ -555,19 +535,139 @@ func getFlag(f int) (string, string) {
 }
 
 // getRPMFiles return a list with all files with suffix .rpm
-// within the directory root
-func getRPMFiles(baseDir string) ([]string, error) {
-	var ls []string
-	err := filepath.WalkDir(baseDir, func(path string, d fs.DirEntry, _ error) error {
-		if !d.IsDir() && strings.HasSuffix(path, ".rpm") {
-			path, _ = strings.CutPrefix(path, baseDir)
-			ls = append(ls, path)
+// within the directory root. Paths relative to root matching an
+// exclude pattern are skipped, and a matching directory is not
+// descended into. If there are include patterns, only files matching
+// at least one of them are returned. Symbolic links to directories
+// are followed if FollowSymlinks is set. A PruneDir within root is
+// skipped, as the RPMs moved there are not to be published.
+func getRPMFiles(baseDir string, config *Config) ([]string, error) {
+	w := &rpmWalker{baseDir: baseDir, config: config}
+
+	if config.PruneAction == "move" && config.PruneDir != "" {
+		base, err := filepath.Abs(baseDir)
+		if err != nil {
+			return nil, err
 		}
-		return nil
-	})
-	if err != nil {
+		pruneDir, err := filepath.Abs(config.PruneDir)
+		if err != nil {
+			return nil, err
+		}
+		if rel, err := filepath.Rel(base, pruneDir); err == nil && withinDir(base, pruneDir) {
+			w.pruneDir = filepath.ToSlash(rel)
+		}
+	}
+
+	if config.FollowSymlinks {
+		realBase, err := filepath.EvalSymlinks(baseDir)
+		if err != nil {
+			return nil, err
+		}
+		w.realBase = realBase
+		w.ancestors = map[string]bool{realBase: true}
+	}
+
+	if err := w.walk(baseDir, "", w.realBase); err != nil {
 		return nil, err
 	}
 
-	return ls, nil
+	return w.ls, nil
+}
+
+// rpmWalker walks a repository directory looking for RPMs.
+type rpmWalker struct {
+	baseDir   string
+	config    *Config
+	realBase  string
+	pruneDir  string
+	ancestors map[string]bool
+	ls        []string
+}
+
+// walk adds the RPMs in dir to the list. The name is the path of dir
+// relative to the base directory, and realDir is the path of dir with
+// all symbolic links resolved, if symbolic links are followed.
+// An unreadable base directory is an error, while unreadable
+// subdirectories are skipped with a warning.
+func (w *rpmWalker) walk(dir, name, realDir string) error {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		if name == "" {
+			return err
+		}
+		fmt.Fprintf(os.Stderr, "getRPMFiles: %v: skipped\n", err)
+		return nil
+	}
+
+	for _, e := range entries {
+		path := dir + "/" + e.Name()
+		entryName := name + "/" + e.Name()
+		rel := strings.TrimPrefix(filepath.ToSlash(entryName), "/")
+		if matchAny(w.config.Exclude, rel) || rel == w.pruneDir {
+			continue
+		}
+
+		isDir := e.IsDir()
+		realPath := realDir + "/" + e.Name()
+		if e.Type()&fs.ModeSymlink != 0 && w.config.FollowSymlinks {
+			target, err := filepath.EvalSymlinks(path)
+			if err != nil {
+				continue
+			}
+			if !w.config.AllowExternalSymlinks && !withinDir(w.realBase, target) {
+				fmt.Fprintf(os.Stderr, "getRPMFiles: %s: link target %s is outside of %s: skipped\n", path, target, w.baseDir)
+				continue
+			}
+			fi, err := os.Stat(target)
+			if err != nil {
+				continue
+			}
+			isDir = fi.IsDir()
+			realPath = target
+		}
+
+		if isDir {
+			if w.config.FollowSymlinks {
+				if w.ancestors[realPath] {
+					fmt.Fprintf(os.Stderr, "getRPMFiles: %s: symbolic link loop: skipped\n", path)
+					continue
+				}
+				w.ancestors[realPath] = true
+				err := w.walk(path, entryName, realPath)
+				delete(w.ancestors, realPath)
+				if err != nil {
+					return err
+				}
+			} else if err := w.walk(path, entryName, realPath); err != nil {
+				return err
+			}
+			continue
+		}
+
+		if strings.HasSuffix(e.Name(), ".rpm") &&
+			(len(w.config.Include) == 0 || matchAny(w.config.Include, rel)) {
+			w.ls = append(w.ls, entryName)
+		}
+	}
+
+	return nil
+}
+
+// withinDir returns true if path is dir or within dir.
+func withinDir(dir, path string) bool {
+	rel, err := filepath.Rel(dir, path)
+
+	return err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator))
+}
+
+// matchAny returns true if name matches any of the doublestar glob
+// patterns.
+func matchAny(patterns []string, name string) bool {
+	for _, pattern := range patterns {
+		if doublestar.MatchUnvalidated(pattern, name) {
+			return true
+		}
+	}
+
+	return false
 }
@@ -1,7 +1,10 @@
 package createrepo
 
 import (
+	"encoding/binary"
 	"fmt"
+	"os"
+	"path/filepath"
 	"strings"
 	"testing"
 )
@@ -73,3 +76,343 @@ func TestReadParenthesis(t *testing.T) {
 		}
 	}
 }
+
+func TestGetRPMFiles(t *testing.T) {
+	dir := t.TempDir()
+	for _, name := range []string{
+		"a.rpm",
+		"x86_64/b.rpm",
+		"x86_64/b-debuginfo-1.rpm",
+		"x86_64/debug/c.rpm",
+		".snapshot/hourly/a.rpm",
+		"noarch/d.rpm",
+		"noarch/readme.txt",
+	} {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0777); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, nil, 0666); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tests := []struct {
+		Include []string
+		Exclude []string
+		Expect  string
+	}{
+		{nil, nil, "/.snapshot/hourly/a.rpm, /a.rpm, /noarch/d.rpm, /x86_64/b-debuginfo-1.rpm, /x86_64/b.rpm, /x86_64/debug/c.rpm"},
+		{nil, []string{".snapshot", "**/debug", "**/*-debuginfo-*"}, "/a.rpm, /noarch/d.rpm, /x86_64/b.rpm"},
+		{[]string{"x86_64/**"}, []string{"**/debug"}, "/x86_64/b-debuginfo-1.rpm, /x86_64/b.rpm"},
+		{[]string{"*.rpm", "noarch/*"}, nil, "/a.rpm, /noarch/d.rpm"},
+	}
+
+	for _, test := range tests {
+		ls, err := getRPMFiles(dir, &Config{Include: test.Include, Exclude: test.Exclude})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := strings.Join(ls, ", "); got != test.Expect {
+			t.Fatalf("getRPMFiles(%q, %q): got %q, expected %q", test.Include, test.Exclude, got, test.Expect)
+		}
+	}
+}
+
+func TestGetRPMFilesSymlinks(t *testing.T) {
+	root := t.TempDir()
+	dir := filepath.Join(root, "repo")
+	for _, name := range []string{
+		"repo/x86_64/a.rpm",
+		"repo/pool/noarch/b.rpm",
+		"outside/c.rpm",
+	} {
+		path := filepath.Join(root, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0777); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, nil, 0666); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for link, target := range map[string]string{
+		"repo/x86_64/noarch": "../pool/noarch",
+		"repo/x86_64/loop":   "..",
+		"repo/external":      "../outside",
+	} {
+		if err := os.Symlink(target, filepath.Join(root, link)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tests := []struct {
+		Follow bool
+		Allow  bool
+		Expect string
+	}{
+		{false, false, "/pool/noarch/b.rpm, /x86_64/a.rpm"},
+		{true, false, "/pool/noarch/b.rpm, /x86_64/a.rpm, /x86_64/noarch/b.rpm"},
+		{true, true, "/external/c.rpm, /pool/noarch/b.rpm, /x86_64/a.rpm, /x86_64/noarch/b.rpm"},
+	}
+
+	for _, test := range tests {
+		ls, err := getRPMFiles(dir, &Config{FollowSymlinks: test.Follow, AllowExternalSymlinks: test.Allow})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := strings.Join(ls, ", "); got != test.Expect {
+			t.Fatalf("getRPMFiles(follow=%v, allow=%v): got %q, expected %q", test.Follow, test.Allow, got, test.Expect)
+		}
+	}
+}
+
+func TestGetRPMFilesUnreadable(t *testing.T) {
+	dir := t.TempDir()
+	if _, err := getRPMFiles(dir+"/missing", &Config{}); err == nil {
+		t.Fatalf("got no error for a missing directory")
+	}
+
+	if os.Geteuid() == 0 {
+		t.Skip("unreadable directories are readable by root")
+	}
+	for _, name := range []string{"a.rpm", "locked/b.rpm"} {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0777); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, nil, 0666); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := os.Chmod(dir+"/locked", 0); err != nil {
+		t.Fatal(err)
+	}
+	defer os.Chmod(dir+"/locked", 0777)
+
+	ls, err := getRPMFiles(dir, &Config{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := strings.Join(ls, ", "); got != "/a.rpm" {
+		t.Fatalf("got %q, expected /a.rpm", got)
+	}
+}
+
+// testRPM describes an RPM written by writeTestRPM.
+type testRPM struct {
+	Name      string
+	Epoch     int
+	Version   string
+	Release   string
+	Arch      string
+	Summary   string
+	BuildTime int64
+	Provides  []string
+	Requires  []string
+	Files     []string
+	Changelog []*testChangelog
+}
+
+// testChangelog represents a changelog entry of a testRPM. The
+// entries are stored in the order given, newest first in real RPMs.
+type testChangelog struct {
+	Time   int64
+	Author string
+	Text   string
+}
+
+// testHeader builds an RPM header structure.
+type testHeader struct {
+	index []byte
+	store []byte
+	count int
+}
+
+// add adds a tag with count values of the given type encoded in data.
+func (h *testHeader) add(tag, typ, count int, data []byte) {
+	align := map[int]int{3: 2, 4: 4, 5: 8}[typ]
+	for align > 0 && len(h.store)%align != 0 {
+		h.store = append(h.store, 0)
+	}
+	for _, v := range []int{tag, typ, len(h.store), count} {
+		h.index = binary.BigEndian.AppendUint32(h.index, uint32(v))
+	}
+	h.store = append(h.store, data...)
+	h.count++
+}
+
+func (h *testHeader) addString(tag int, s string) {
+	h.add(tag, 6, 1, append([]byte(s), 0))
+}
+
+func (h *testHeader) addStrings(tag int, ss []string) {
+	var data []byte
+	for _, s := range ss {
+		data = append(append(data, s...), 0)
+	}
+	h.add(tag, 8, len(ss), data)
+}
+
+func (h *testHeader) addInt32(tag int, vs ...int64) {
+	var data []byte
+	for _, v := range vs {
+		data = binary.BigEndian.AppendUint32(data, uint32(v))
+	}
+	h.add(tag, 4, len(vs), data)
+}
+
+func (h *testHeader) addInt16(tag int, vs ...int64) {
+	var data []byte
+	for _, v := range vs {
+		data = binary.BigEndian.AppendUint16(data, uint16(v))
+	}
+	h.add(tag, 3, len(vs), data)
+}
+
+// addDependencies adds the name, flags and version tags of
+// dependencies given as in a spec file, e.g. "foo >= 1.0-1".
+func (h *testHeader) addDependencies(nameTag, flagsTag, versionTag int, deps []string) {
+	if len(deps) == 0 {
+		return
+	}
+
+	ops := map[string]int64{"<": 2, ">": 4, "=": 8, "<=": 10, ">=": 12}
+	var names, versions []string
+	var flags []int64
+	for _, dep := range deps {
+		fields := strings.Fields(dep)
+		names = append(names, fields[0])
+		if len(fields) == 3 {
+			flags = append(flags, ops[fields[1]])
+			versions = append(versions, fields[2])
+		} else {
+			flags = append(flags, 0)
+			versions = append(versions, "")
+		}
+	}
+	h.addStrings(nameTag, names)
+	h.addInt32(flagsTag, flags...)
+	h.addStrings(versionTag, versions)
+}
+
+func (h *testHeader) bytes() []byte {
+	b := []byte{0x8e, 0xad, 0xe8, 0x01, 0, 0, 0, 0}
+	b = binary.BigEndian.AppendUint32(b, uint32(h.count))
+	b = binary.BigEndian.AppendUint32(b, uint32(len(h.store)))
+
+	return append(append(b, h.index...), h.store...)
+}
+
+// writeTestRPM writes an RPM with the header described by p, an empty
+// signature header and no payload, to the named file.
+func writeTestRPM(t *testing.T, name string, p *testRPM) {
+	t.Helper()
+
+	lead := make([]byte, 96)
+	copy(lead, []byte{0xed, 0xab, 0xee, 0xdb, 3, 0})
+	copy(lead[10:76], p.Name)
+
+	h := &testHeader{}
+	h.addString(1000, p.Name)
+	h.addString(1001, p.Version)
+	h.addString(1002, p.Release)
+	if p.Epoch > 0 {
+		h.addInt32(1003, int64(p.Epoch))
+	}
+	h.addString(1004, p.Summary)
+	h.addString(1005, p.Summary)
+	h.addInt32(1006, p.BuildTime)
+	h.addString(1014, "MIT")
+	h.addString(1022, p.Arch)
+	h.addString(1044, p.Name+"-"+p.Version+"-"+p.Release+".src.rpm")
+	h.addDependencies(1047, 1112, 1113, p.Provides)
+	h.addDependencies(1049, 1048, 1050, p.Requires)
+
+	if len(p.Files) > 0 {
+		var dirs, bases, empty, root []string
+		var indexes, modes, zeros []int64
+		for i, f := range p.Files {
+			n := strings.LastIndex(f, "/")
+			dirs = append(dirs, f[:n+1])
+			bases = append(bases, f[n+1:])
+			indexes = append(indexes, int64(i))
+			modes = append(modes, 0100644)
+			zeros = append(zeros, 0)
+			empty = append(empty, "")
+			root = append(root, "root")
+		}
+		h.addInt32(1028, zeros...)
+		h.addInt16(1030, modes...)
+		h.addInt32(1034, zeros...)
+		h.addStrings(1035, empty)
+		h.addStrings(1036, empty)
+		h.addInt32(1037, zeros...)
+		h.addStrings(1039, root)
+		h.addStrings(1040, root)
+		h.addInt32(1116, indexes...)
+		h.addStrings(1117, bases)
+		h.addStrings(1118, dirs)
+	}
+
+	if len(p.Changelog) > 0 {
+		var times []int64
+		var authors, texts []string
+		for _, c := range p.Changelog {
+			times = append(times, c.Time)
+			authors = append(authors, c.Author)
+			texts = append(texts, c.Text)
+		}
+		h.addInt32(1080, times...)
+		h.addStrings(1081, authors)
+		h.addStrings(1082, texts)
+	}
+
+	b := append(lead, (&testHeader{}).bytes()...)
+	b = append(b, h.bytes()...)
+
+	if err := os.MkdirAll(filepath.Dir(name), 0777); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(name, b, 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestGetChangelog(t *testing.T) {
+	dir := t.TempDir()
+	writeTestRPM(t, dir+"/a.rpm", &testRPM{Name: "a", Version: "1.0", Release: "3", Arch: "noarch",
+		Changelog: []*testChangelog{
+			{Time: 300, Author: "C <c@example.com> - 1.0-3", Text: "- third"},
+			{Time: 200, Author: "B <b@example.com> - 1.0-2", Text: "- second"},
+			{Time: 100, Author: "A <a@example.com> - 1.0-1", Text: "- first"},
+		},
+	})
+	writeTestRPM(t, dir+"/b.rpm", &testRPM{Name: "b", Version: "1.0", Release: "1", Arch: "noarch"})
+
+	tests := []struct {
+		Name   string
+		Limit  int
+		Expect string
+	}{
+		{"/a.rpm", 0, "100 - first, 200 - second, 300 - third"},
+		{"/a.rpm", 2, "200 - second, 300 - third"},
+		{"/a.rpm", 1, "300 - third"},
+		{"/a.rpm", 5, "100 - first, 200 - second, 300 - third"},
+		{"/b.rpm", 0, ""},
+		{"/b.rpm", 1, ""},
+	}
+
+	for _, test := range tests {
+		_, _, o, err := getPackage(dir, test.Name, &Config{ChecksumType: "sha256", ChangelogLimit: test.Limit}, NewNoChecksumCache())
+		if err != nil {
+			t.Fatal(err)
+		}
+		var ls []string
+		for _, c := range o.Changelog {
+			ls = append(ls, fmt.Sprintf("%d %s", c.Date, c.Text))
+		}
+		if got := strings.Join(ls, ", "); got != test.Expect {
+			t.Fatalf("changelog of %s with limit %d: got %q, expected %q", test.Name, test.Limit, got, test.Expect)
+		}
+	}
+}
@@ -0,0 +1,357 @@
+package createrepo
+
+import (
+	"database/sql"
+	"fmt"
+	"io"
+	_ "modernc.org/sqlite"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// dbVersion is the version of the createrepo sqlite database schema.
+const dbVersion = 10
+
+const primarySchema = `
+CREATE TABLE db_info (dbversion INTEGER, checksum TEXT);
+CREATE TABLE packages (pkgKey INTEGER PRIMARY KEY, pkgId TEXT, name TEXT, arch TEXT, version TEXT, epoch TEXT, release TEXT, summary TEXT, description TEXT, url TEXT, time_file INTEGER, time_build INTEGER, rpm_license TEXT, rpm_vendor TEXT, rpm_group TEXT, rpm_buildhost TEXT, rpm_sourcerpm TEXT, rpm_header_start INTEGER, rpm_header_end INTEGER, rpm_packager TEXT, size_package INTEGER, size_installed INTEGER, size_archive INTEGER, location_href TEXT, location_base TEXT, checksum_type TEXT);
+CREATE TABLE files (name TEXT, type TEXT, pkgKey INTEGER);
+CREATE TABLE requires (name TEXT, flags TEXT, epoch TEXT, version TEXT, release TEXT, pkgKey INTEGER, pre BOOLEAN DEFAULT FALSE);
+CREATE TABLE provides (name TEXT, flags TEXT, epoch TEXT, version TEXT, release TEXT, pkgKey INTEGER);
+CREATE TABLE conflicts (name TEXT, flags TEXT, epoch TEXT, version TEXT, release TEXT, pkgKey INTEGER);
+CREATE TABLE obsoletes (name TEXT, flags TEXT, epoch TEXT, version TEXT, release TEXT, pkgKey INTEGER);
+CREATE TABLE suggests (name TEXT, flags TEXT, epoch TEXT, version TEXT, release TEXT, pkgKey INTEGER);
+CREATE TABLE enhances (name TEXT, flags TEXT, epoch TEXT, version TEXT, release TEXT, pkgKey INTEGER);
+CREATE TABLE recommends (name TEXT, flags TEXT, epoch TEXT, version TEXT, release TEXT, pkgKey INTEGER);
+CREATE TABLE supplements (name TEXT, flags TEXT, epoch TEXT, version TEXT, release TEXT, pkgKey INTEGER);
+CREATE INDEX packagename ON packages (name);
+CREATE INDEX packageId ON packages (pkgId);
+CREATE INDEX filenames ON files (name);
+CREATE INDEX pkgfiles ON files (pkgKey);
+CREATE INDEX pkgrequires ON requires (pkgKey);
+CREATE INDEX requiresname ON requires (name);
+CREATE INDEX pkgprovides ON provides (pkgKey);
+CREATE INDEX providesname ON provides (name);
+CREATE INDEX pkgconflicts ON conflicts (pkgKey);
+CREATE INDEX pkgobsoletes ON obsoletes (pkgKey);
+CREATE INDEX pkgsuggests ON suggests (pkgKey);
+CREATE INDEX pkgenhances ON enhances (pkgKey);
+CREATE INDEX pkgrecommends ON recommends (pkgKey);
+CREATE INDEX pkgsupplements ON supplements (pkgKey);
+CREATE TRIGGER removals AFTER DELETE ON packages BEGIN
+  DELETE FROM files WHERE pkgKey = old.pkgKey;
+  DELETE FROM requires WHERE pkgKey = old.pkgKey;
+  DELETE FROM provides WHERE pkgKey = old.pkgKey;
+  DELETE FROM conflicts WHERE pkgKey = old.pkgKey;
+  DELETE FROM obsoletes WHERE pkgKey = old.pkgKey;
+  DELETE FROM suggests WHERE pkgKey = old.pkgKey;
+  DELETE FROM enhances WHERE pkgKey = old.pkgKey;
+  DELETE FROM recommends WHERE pkgKey = old.pkgKey;
+  DELETE FROM supplements WHERE pkgKey = old.pkgKey;
+END;
+`
+
+const fileListsSchema = `
+CREATE TABLE db_info (dbversion INTEGER, checksum TEXT);
+CREATE TABLE packages (pkgKey INTEGER PRIMARY KEY, pkgId TEXT);
+CREATE TABLE filelist (pkgKey INTEGER, dirname TEXT, filenames TEXT, filetypes TEXT);
+CREATE INDEX keyfile ON filelist (pkgKey);
+CREATE INDEX pkgId ON packages (pkgId);
+CREATE INDEX dirnames ON filelist (dirname);
+CREATE TRIGGER remove_filelist AFTER DELETE ON packages BEGIN
+  DELETE FROM filelist WHERE pkgKey = old.pkgKey;
+END;
+`
+
+const otherSchema = `
+CREATE TABLE db_info (dbversion INTEGER, checksum TEXT);
+CREATE TABLE packages (pkgKey INTEGER PRIMARY KEY, pkgId TEXT);
+CREATE TABLE changelog (pkgKey INTEGER, author TEXT, date INTEGER, changelog TEXT);
+CREATE INDEX keychange ON changelog (pkgKey);
+CREATE INDEX pkgId ON packages (pkgId);
+CREATE TRIGGER remove_changelogs AFTER DELETE ON packages BEGIN
+  DELETE FROM changelog WHERE pkgKey = old.pkgKey;
+END;
+`
+
+// databaseTypes are the data types of the sqlite databases.
+var databaseTypes = []string{"primary_db", "filelists_db", "other_db"}
+
+// stageDatabases writes the primary, filelists and other sqlite
+// databases and stages them compressed with the canonical compression
+// algorithm. The db_info table of each database refers to the checksum
+// of its staged XML counterpart, which must already be staged.
+func (r *dataSet) stageDatabases(baseDir string, config *Config) error {
+	xmlChecksums := make(map[string]string)
+	for _, s := range r.staged {
+		xmlChecksums[s.dataType] = s.checksum.Data
+	}
+
+	dbs := []struct {
+		dataType string
+		name     string
+		schema   string
+		fill     func(*sql.Tx) error
+	}{
+		{"primary", "primary.sqlite", primarySchema, r.primary.fillDatabase},
+		{"filelists", "filelists.sqlite", fileListsSchema, r.fileLists.fillDatabase},
+		{"other", "other.sqlite", otherSchema, r.other.fillDatabase},
+	}
+
+	for _, db := range dbs {
+		s, err := stageDatabase(baseDir, db.dataType+"_db", db.name, db.schema, xmlChecksums[db.dataType], config, db.fill)
+		if err != nil {
+			return fmt.Errorf("%s: %v", db.name, err)
+		}
+		r.staged = append(r.staged, s)
+	}
+
+	return nil
+}
+
+// stageDatabase creates a sqlite database in a temporary file with
+// the schema, fills it and stages it compressed.
+func stageDatabase(baseDir, dataType, name, schema, xmlChecksum string, config *Config, fill func(*sql.Tx) error) (*stagedFile, error) {
+	f, err := os.CreateTemp(baseDir+"/"+repoDataDir, name+"-*.tmp")
+	if err != nil {
+		return nil, err
+	}
+	dbName := f.Name()
+	f.Close()
+	defer os.Remove(dbName)
+
+	if err := writeDatabase(dbName, schema, xmlChecksum, fill); err != nil {
+		return nil, err
+	}
+
+	s, err := stageFiles(baseDir, dataType, name, []string{config.CompressAlgo}, config.CompressLevel, config.ChecksumType, func(w chunkWriter) error {
+		f, err := os.Open(dbName)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+
+		_, err = io.Copy(w, f)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	s[0].databaseVersion = dbVersion
+
+	return s[0], nil
+}
+
+// writeDatabase creates the named sqlite database with the schema and
+// fills it in a single transaction.
+func writeDatabase(name, schema, xmlChecksum string, fill func(*sql.Tx) error) error {
+	db, err := sql.Open("sqlite", name)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	if _, err := db.Exec("PRAGMA synchronous = OFF; PRAGMA journal_mode = MEMORY;"); err != nil {
+		return err
+	}
+
+	if _, err := db.Exec(schema); err != nil {
+		return err
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec("INSERT INTO db_info (dbversion, checksum) VALUES (?, ?)", dbVersion, xmlChecksum); err != nil {
+		return err
+	}
+
+	if err := fill(tx); err != nil {
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+
+	return db.Close()
+}
+
+// fillDatabase inserts the primary packages into the primary database.
+func (p *primary) fillDatabase(tx *sql.Tx) error {
+	pkgStmt, err := tx.Prepare(`INSERT INTO packages (pkgKey, pkgId, name, arch, version, epoch, release, summary, description, url, time_file, time_build, rpm_license, rpm_vendor, rpm_group, rpm_buildhost, rpm_sourcerpm, rpm_packager, size_package, size_installed, size_archive, location_href, location_base, checksum_type) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`)
+	if err != nil {
+		return err
+	}
+	defer pkgStmt.Close()
+
+	fileStmt, err := tx.Prepare("INSERT INTO files (name, type, pkgKey) VALUES (?, ?, ?)")
+	if err != nil {
+		return err
+	}
+	defer fileStmt.Close()
+
+	depStmts := make(map[string]*sql.Stmt)
+	for _, table := range []string{"requires", "provides", "conflicts", "obsoletes", "suggests", "recommends"} {
+		query := "INSERT INTO " + table + " (name, flags, epoch, version, release, pkgKey) VALUES (?, ?, ?, ?, ?, ?)"
+		if table == "requires" {
+			query = "INSERT INTO requires (name, flags, epoch, version, release, pkgKey, pre) VALUES (?, ?, ?, ?, ?, ?, ?)"
+		}
+		stmt, err := tx.Prepare(query)
+		if err != nil {
+			return err
+		}
+		defer stmt.Close()
+		depStmts[table] = stmt
+	}
+
+	for i, pkg := range p.Packages {
+		key := i + 1
+		f := pkg.Format
+		if _, err := pkgStmt.Exec(key, pkg.Checksum.Data, pkg.Name, pkg.Arch,
+			pkg.Version.Version, strconv.Itoa(pkg.Version.Epoch), pkg.Version.Release,
+			pkg.Summary, pkg.Description, pkg.URL,
+			atoi(pkg.Time.File), atoi(pkg.Time.Build),
+			f.License.License, f.Vendor.Vendor, f.Group.Group, f.BuildHost.BuildHost, f.SourceRPM.SourceRPM,
+			pkg.Packager, atoi(pkg.Size.Package), atoi(pkg.Size.Installed), atoi(pkg.Size.Archive),
+			pkg.Location.Href, nullString(pkg.Location.Base), pkg.Checksum.Type); err != nil {
+			return err
+		}
+
+		for _, file := range f.Files {
+			if _, err := fileStmt.Exec(file.Path, "file", key); err != nil {
+				return err
+			}
+		}
+
+		deps := []struct {
+			table   string
+			entries []*entry
+		}{
+			{"requires", f.Requires},
+			{"provides", f.Provides},
+			{"conflicts", f.Conflicts},
+			{"obsoletes", f.Obsoletes},
+			{"suggests", f.Suggests},
+			{"recommends", f.Recommends},
+		}
+		for _, dep := range deps {
+			table := dep.table
+			for _, e := range dep.entries {
+				args := []any{e.Name, nullString(e.Flags), nullString(e.Epoch), nullString(e.Version), nullString(e.Release), key}
+				if table == "requires" {
+					pre := "FALSE"
+					if e.Pre != "" {
+						pre = "TRUE"
+					}
+					args = append(args, pre)
+				}
+				if _, err := depStmts[table].Exec(args...); err != nil {
+					return err
+				}
+			}
+		}
+	}
+
+	return nil
+}
+
+// fillDatabase inserts the file lists into the filelists database,
+// with one row per directory of each package.
+func (f *fileLists) fillDatabase(tx *sql.Tx) error {
+	pkgStmt, err := tx.Prepare("INSERT INTO packages (pkgKey, pkgId) VALUES (?, ?)")
+	if err != nil {
+		return err
+	}
+	defer pkgStmt.Close()
+
+	fileStmt, err := tx.Prepare("INSERT INTO filelist (pkgKey, dirname, filenames, filetypes) VALUES (?, ?, ?, ?)")
+	if err != nil {
+		return err
+	}
+	defer fileStmt.Close()
+
+	for i, pkg := range f.Packages {
+		key := i + 1
+		if _, err := pkgStmt.Exec(key, pkg.PkgID); err != nil {
+			return err
+		}
+
+		var dirs []string
+		names := make(map[string][]string)
+		types := make(map[string]string)
+		for _, file := range pkg.Files {
+			dir, name := "/", file.Path
+			if n := strings.LastIndex(file.Path, "/"); n > 0 {
+				dir, name = file.Path[:n], file.Path[n+1:]
+			} else if n == 0 {
+				name = file.Path[1:]
+			}
+			if _, ok := names[dir]; !ok {
+				dirs = append(dirs, dir)
+			}
+			names[dir] = append(names[dir], name)
+			if file.Type == "dir" {
+				types[dir] += "d"
+			} else {
+				types[dir] += "f"
+			}
+		}
+
+		for _, dir := range dirs {
+			if _, err := fileStmt.Exec(key, dir, strings.Join(names[dir], "/"), types[dir]); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// fillDatabase inserts the changelogs into the other database.
+func (o *other) fillDatabase(tx *sql.Tx) error {
+	pkgStmt, err := tx.Prepare("INSERT INTO packages (pkgKey, pkgId) VALUES (?, ?)")
+	if err != nil {
+		return err
+	}
+	defer pkgStmt.Close()
+
+	logStmt, err := tx.Prepare("INSERT INTO changelog (pkgKey, author, date, changelog) VALUES (?, ?, ?, ?)")
+	if err != nil {
+		return err
+	}
+	defer logStmt.Close()
+
+	for i, pkg := range o.Packages {
+		key := i + 1
+		if _, err := pkgStmt.Exec(key, pkg.PkgID); err != nil {
+			return err
+		}
+
+		for _, c := range pkg.Changelog {
+			if _, err := logStmt.Exec(key, c.Author, c.Date, c.Text); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// atoi returns the integer value of s, or zero if s is not an integer.
+func atoi(s string) int64 {
+	n, _ := strconv.ParseInt(s, 10, 64)
+	return n
+}
+
+// nullString returns nil for an empty string, so it is stored as NULL.
+func nullString(s string) any {
+	if s == "" {
+		return nil
+	}
+	return s
+}
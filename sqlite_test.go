@@ -0,0 +1,158 @@
+package createrepo
+
+import (
+	"database/sql"
+	"os"
+	"strings"
+	"testing"
+)
+
+// queryRows returns the rows of the query, with the columns of each
+// row joined by a space.
+func queryRows(t *testing.T, db *sql.DB, query string) string {
+	t.Helper()
+
+	rows, err := db.Query(query)
+	if err != nil {
+		t.Fatalf("%s: %v", query, err)
+	}
+	defer rows.Close()
+
+	columns, err := rows.Columns()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var ret []string
+	for rows.Next() {
+		values := make([]sql.NullString, len(columns))
+		ptrs := make([]any, len(columns))
+		for i := range values {
+			ptrs[i] = &values[i]
+		}
+		if err := rows.Scan(ptrs...); err != nil {
+			t.Fatal(err)
+		}
+		var fields []string
+		for _, v := range values {
+			if v.Valid {
+				fields = append(fields, v.String)
+			} else {
+				fields = append(fields, "NULL")
+			}
+		}
+		ret = append(ret, strings.Join(fields, " "))
+	}
+	if err := rows.Err(); err != nil {
+		t.Fatal(err)
+	}
+
+	return strings.Join(ret, "\n")
+}
+
+// openDatabase opens a decompressed copy of the database of the given
+// data type in the repository in dir.
+func openDatabase(t *testing.T, dir string, repomd *repoMD, dataType string) *sql.DB {
+	t.Helper()
+
+	d := repomd.find(dataType)
+	if d == nil {
+		t.Fatalf("no %s in repomd.xml", dataType)
+	}
+	if d.DatabaseVersion == nil || *d.DatabaseVersion != dbVersion {
+		t.Fatalf("%s: got database version %v, expected %d", dataType, d.DatabaseVersion, dbVersion)
+	}
+
+	content, err := d.read(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	name := t.TempDir() + "/" + dataType + ".sqlite"
+	if err := os.WriteFile(name, content, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	db, err := sql.Open("sqlite", name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+
+	return db
+}
+
+func TestDatabases(t *testing.T) {
+	dir := t.TempDir()
+	writeTestRPM(t, dir+"/a.rpm", &testRPM{Name: "a", Version: "1.0", Release: "1", Arch: "x86_64",
+		Provides: []string{"a = 1.0-1", "liba.so.1()(64bit)"},
+		Requires: []string{"b >= 2.0", "/bin/sh"},
+		Files:    []string{"/usr/bin/a", "/usr/share/doc/a/README", "/usr/share/doc/a/NEWS", "/etc/a.conf"},
+		Changelog: []*testChangelog{
+			{Time: 200, Author: "B - 1.0-1", Text: "- second"},
+			{Time: 100, Author: "A - 0.9-1", Text: "- first"},
+		},
+	})
+	writeTestRPM(t, dir+"/b.rpm", &testRPM{Name: "b", Epoch: 1, Version: "2.0", Release: "1", Arch: "noarch",
+		Provides: []string{"b = 1:2.0-1"},
+	})
+
+	r, err := NewRepo(dir, &Config{Database: true, ChecksumCacheType: "none"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := r.Create(); err != nil {
+		t.Fatal(err)
+	}
+	repomd, err := r.readRepoMD()
+	if err != nil {
+		t.Fatal(err)
+	}
+	repo, err := OpenRepo(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	a := repo.PackageByNEVRA("a-1.0-1.x86_64")
+	b := repo.PackageByNEVRA("b-1:2.0-1.noarch")
+	if a == nil || b == nil {
+		t.Fatalf("packages not found")
+	}
+
+	for _, dataType := range []string{"primary", "filelists", "other"} {
+		db := openDatabase(t, dir, repomd, dataType+"_db")
+		expect := "10 " + repomd.find(dataType).Checksum.Data
+		if got := queryRows(t, db, "SELECT dbversion, checksum FROM db_info"); got != expect {
+			t.Fatalf("%s db_info: got %q, expected %q", dataType, got, expect)
+		}
+	}
+
+	tests := []struct {
+		DataType string
+		Query    string
+		Expect   string
+	}{
+		{"primary_db", "SELECT pkgKey, pkgId, name, arch, epoch, version, release, location_href, checksum_type FROM packages ORDER BY pkgKey",
+			"1 " + a.Checksum + " a x86_64 0 1.0 1 " + a.LocationHref + " sha256\n" +
+				"2 " + b.Checksum + " b noarch 1 2.0 1 " + b.LocationHref + " sha256"},
+		{"primary_db", "SELECT pkgKey, name, flags, epoch, version, release FROM provides ORDER BY pkgKey, name",
+			"1 a EQ NULL 1.0 1\n1 liba.so.1()(64bit) NULL NULL NULL NULL\n2 b EQ 1 2.0 1"},
+		{"primary_db", "SELECT pkgKey, name, flags, epoch, version, release, pre FROM requires ORDER BY pkgKey, name",
+			"1 /bin/sh NULL NULL NULL NULL FALSE\n1 b GE NULL 2.0 NULL FALSE"},
+		{"primary_db", "SELECT pkgKey, name, type FROM files ORDER BY pkgKey, name",
+			"1 /etc/a.conf file\n1 /usr/bin/a file"},
+		{"filelists_db", "SELECT pkgKey, pkgId FROM packages ORDER BY pkgKey",
+			"1 " + a.Checksum + "\n2 " + b.Checksum},
+		{"filelists_db", "SELECT pkgKey, dirname, filenames, filetypes FROM filelist ORDER BY pkgKey, dirname",
+			"1 /etc a.conf f\n1 /usr/bin a f\n1 /usr/share/doc/a README/NEWS ff"},
+		{"other_db", "SELECT pkgKey, pkgId FROM packages ORDER BY pkgKey",
+			"1 " + a.Checksum + "\n2 " + b.Checksum},
+		{"other_db", "SELECT pkgKey, author, date, changelog FROM changelog ORDER BY pkgKey, date",
+			"1 A - 0.9-1 100 - first\n1 B - 1.0-1 200 - second"},
+	}
+
+	for _, test := range tests {
+		db := openDatabase(t, dir, repomd, test.DataType)
+		if got := queryRows(t, db, test.Query); got != test.Expect {
+			t.Fatalf("%s: %s: got\n%s\nexpected\n%s", test.DataType, test.Query, got, test.Expect)
+		}
+	}
+}
@@ -0,0 +1,235 @@
+package createrepo
+
+import (
+	"bufio"
+	"fmt"
+	"hash"
+	"io"
+	"os"
+)
+
+// stagedFile represents a compressed metadata file written to a
+// temporary file in repodata, waiting to be moved in place.
+type stagedFile struct {
+	dataType        string
+	name            string
+	suffix          string
+	tmpName         string
+	checksum        *checksum
+	size            uint64
+	openChecksum    *checksum
+	openSize        uint64
+	headerChecksum  *checksum
+	headerSize      uint64
+	databaseVersion int
+}
+
+// stageOutput represents the compressed output of one algorithm
+// while staging.
+type stageOutput struct {
+	file  *os.File
+	buf   *bufio.Writer
+	z     io.WriteCloser
+	zck   *zckWriter
+	hash  hash.Hash
+	count *countWriter
+}
+
+// stageWriter writes the uncompressed content to all outputs, and
+// passes chunk boundaries on to the zchunk output if any.
+type stageWriter struct {
+	io.Writer
+	zck *zckWriter
+}
+
+func (s *stageWriter) endChunk() error {
+	if s.zck == nil {
+		return nil
+	}
+	return s.zck.endChunk()
+}
+
+// stageFiles streams the output of encode through the open checksum
+// hasher and one compressor per algorithm into temporary files in
+// repodata. The checksum and size of both the uncompressed and the
+// compressed content are computed on the way, so the content is never
+// held in memory, and is encoded once for all algorithms. This is the
+// second encoding of the content, after dataSet.checksum.
+//
+// The first algorithm is staged as dataType, the others as dataType
+// suffixed with _<algo>. The algorithm zck stages a zchunk file.
+func stageFiles(baseDir, dataType, name string, compressAlgos []string, compressLevel int, checksumType string, encode func(chunkWriter) error) (s []*stagedFile, e error) {
+	var outputs []*stageOutput
+	defer func() {
+		if e != nil {
+			for _, o := range outputs {
+				if o.zck != nil {
+					o.zck.discard()
+				}
+				o.file.Close()
+				os.Remove(o.file.Name())
+			}
+		}
+	}()
+
+	openHash, err := newHash(checksumType)
+	if err != nil {
+		return nil, err
+	}
+	openCount := &countWriter{}
+	writers := []io.Writer{openHash, openCount}
+	sw := &stageWriter{}
+	var suffixes []string
+	for _, algo := range compressAlgos {
+		f, err := os.CreateTemp(baseDir+"/"+repoDataDir, name+"-*.tmp")
+		if err != nil {
+			return nil, err
+		}
+		h, err := newHash(checksumType)
+		if err != nil {
+			f.Close()
+			os.Remove(f.Name())
+			return nil, err
+		}
+		o := &stageOutput{file: f, buf: bufio.NewWriter(f), hash: h, count: &countWriter{}}
+		outputs = append(outputs, o)
+
+		if err := f.Chmod(0644); err != nil {
+			return nil, err
+		}
+
+		w := io.MultiWriter(o.buf, o.hash, o.count)
+		if algo == "zck" {
+			z, err := newZckWriter(w, baseDir+"/"+repoDataDir, compressLevel)
+			if err != nil {
+				return nil, err
+			}
+			o.z, o.zck, sw.zck = z, z, z
+			suffixes = append(suffixes, ".zck")
+		} else {
+			z, suffix, err := newCompressor(w, algo, compressLevel)
+			if err != nil {
+				return nil, err
+			}
+			o.z = z
+			suffixes = append(suffixes, suffix)
+		}
+		writers = append(writers, o.z)
+	}
+	sw.Writer = io.MultiWriter(writers...)
+
+	if err := encode(sw); err != nil {
+		return nil, err
+	}
+
+	for i, o := range outputs {
+		if err := o.z.Close(); err != nil {
+			return nil, err
+		}
+
+		if err := o.buf.Flush(); err != nil {
+			return nil, err
+		}
+
+		if err := o.file.Close(); err != nil {
+			return nil, err
+		}
+
+		f := &stagedFile{
+			dataType:     variantType(dataType, i, compressAlgos[i]),
+			name:         name,
+			suffix:       suffixes[i],
+			tmpName:      o.file.Name(),
+			checksum:     newChecksum(checksumType, o.hash),
+			size:         o.count.n,
+			openChecksum: newChecksum(checksumType, openHash),
+			openSize:     openCount.n,
+		}
+		if o.zck != nil {
+			f.headerChecksum = &checksum{Type: "sha256", Data: fmt.Sprintf("%x", o.zck.headerChecksum)}
+			f.headerSize = o.zck.headerSize
+		}
+		s = append(s, f)
+	}
+
+	return s, nil
+}
+
+// variantType returns the data type of a meta file compressed with
+// the i-th of its compression algorithms: dataType for the first, and
+// dataType suffixed with _<algo> for the others.
+func variantType(dataType string, i int, algo string) string {
+	if i == 0 {
+		return dataType
+	}
+
+	return dataType + "_" + algo
+}
+
+// openChecksum returns the checksum and size of the uncompressed
+// output of encode, without writing it anywhere.
+func openChecksum(checksumType string, encode func(chunkWriter) error) (*checksum, uint64, error) {
+	h, err := newHash(checksumType)
+	if err != nil {
+		return nil, 0, err
+	}
+	count := &countWriter{}
+
+	if err := encode(&stageWriter{Writer: io.MultiWriter(h, count)}); err != nil {
+		return nil, 0, err
+	}
+
+	return newChecksum(checksumType, h), count.n, nil
+}
+
+// commit moves the staged file in place as its name prefixed with
+// its checksum, and returns its data entry in repomd.xml. If the file
+// already exists, its content is the same, and the existing file is
+// kept. The returned bool is true if a new file was created.
+func (s *stagedFile) commit(baseDir string) (*data, bool, error) {
+	href := repoDataDir + "/" + s.checksum.Data + "-" + s.name + s.suffix
+
+	fi, err := os.Stat(baseDir + "/" + href)
+	isNew := err != nil
+	if isNew {
+		if err := os.Rename(s.tmpName, baseDir+"/"+href); err != nil {
+			return nil, false, err
+		}
+
+		fi, err = os.Stat(baseDir + "/" + href)
+		if err != nil {
+			return nil, false, err
+		}
+	} else {
+		s.discard()
+	}
+
+	modTime := uint64(fi.ModTime().Unix())
+	size, openSize := s.size, s.openSize
+
+	d := &data{
+		Type:         s.dataType,
+		Checksum:     s.checksum,
+		OpenChecksum: s.openChecksum,
+		Location:     &location{Href: href},
+		Size:         &size,
+		OpenSize:     &openSize,
+		Timestamp:    &modTime,
+	}
+	if s.databaseVersion != 0 {
+		databaseVersion := s.databaseVersion
+		d.DatabaseVersion = &databaseVersion
+	}
+	if s.headerChecksum != nil {
+		headerSize := s.headerSize
+		d.HeaderChecksum = s.headerChecksum
+		d.HeaderSize = &headerSize
+	}
+
+	return d, isNew, nil
+}
+
+// discard removes the temporary file if it still exists.
+func (s *stagedFile) discard() {
+	os.Remove(s.tmpName)
+}
@@ -0,0 +1,126 @@
+package createrepo
+
+import (
+	"encoding/xml"
+	"fmt"
+	"net/url"
+	"os"
+	"strings"
+)
+
+// cachedPackage represents the metadata of a single package as found
+// in the current repository metadata.
+type cachedPackage struct {
+	primary  *rpmPackage
+	fileList *packageList
+	other    *otherPackage
+}
+
+// unchanged returns true if the named file still has the size and
+// modification time recorded in the cached metadata, the checksum is
+// of the given type, and all parts of the metadata are present.
+func (c *cachedPackage) unchanged(baseDir, name, checksumType string) bool {
+	if c.primary == nil || c.fileList == nil || c.other == nil ||
+		c.primary.Size == nil || c.primary.Time == nil ||
+		c.primary.Checksum.Type != checksumType {
+		return false
+	}
+
+	fi, err := os.Stat(baseDir + "/" + name)
+	if err != nil {
+		return false
+	}
+
+	return c.primary.Size.Package == fmt.Sprintf("%d", fi.Size()) &&
+		c.primary.Time.File == fmt.Sprintf("%d", fi.ModTime().Unix())
+}
+
+// readCachedPackages returns the packages of the current repomd.xml
+// indexed by their unescaped location href, with a leading slash as
+// returned by getRPMFiles. If there is no repomd.xml, or it was not
+// made with the same settings affecting the package metadata, a nil
+// map is returned.
+func (r *Repo) readCachedPackages() (map[string]*cachedPackage, error) {
+	repomd, err := r.readRepoMD()
+	if err != nil || repomd == nil {
+		return nil, err
+	}
+
+	hist, err := readHistory(r.outputDir)
+	if err != nil {
+		return nil, err
+	}
+	if hist == nil {
+		return nil, nil
+	}
+	if rev := hist.find(repomd.Revision); rev == nil || rev.Settings != r.config.fingerprint() {
+		return nil, nil
+	}
+
+	primaryData := repomd.find("primary")
+	fileListsData := repomd.find("filelists")
+	otherData := repomd.find("other")
+
+	if primaryData == nil || fileListsData == nil {
+		return nil, nil
+	}
+
+	p := &primary{}
+	if err := decodeData(primaryData, r.outputDir, p); err != nil {
+		return nil, err
+	}
+
+	f := &fileLists{}
+	if err := decodeData(fileListsData, r.outputDir, f); err != nil {
+		return nil, err
+	}
+
+	o := &other{}
+	if otherData != nil {
+		if err := decodeData(otherData, r.outputDir, o); err != nil {
+			return nil, err
+		}
+	}
+
+	fileListMap := make(map[string]*packageList)
+	for _, pl := range f.Packages {
+		fileListMap[pl.PkgID] = pl
+	}
+
+	otherMap := make(map[string]*otherPackage)
+	for _, op := range o.Packages {
+		otherMap[op.PkgID] = op
+	}
+
+	ret := make(map[string]*cachedPackage)
+	for _, pkg := range p.Packages {
+		if pkg.Location == nil || pkg.Checksum == nil {
+			continue
+		}
+		name, err := url.PathUnescape(pkg.Location.Href)
+		if err != nil {
+			continue
+		}
+		ret["/"+strings.TrimPrefix(name, "/")] = &cachedPackage{
+			primary:  pkg,
+			fileList: fileListMap[pkg.Checksum.Data],
+			other:    otherMap[pkg.Checksum.Data],
+		}
+	}
+
+	return ret, nil
+}
+
+// decodeData reads and decodes the data file into v.
+func decodeData(d *data, baseDir string, v any) error {
+	content, err := d.read(baseDir)
+	if err != nil {
+		return err
+	}
+
+	if err := xml.Unmarshal(content, v); err != nil {
+		return fmt.Errorf("%s: %v", d.Location.Href, err)
+	}
+
+	return nil
+}
@@ -0,0 +1,109 @@
+package createrepo
+
+import (
+	"os"
+	"testing"
+	"time"
+)
+
+func TestUpdate(t *testing.T) {
+	dir := t.TempDir()
+	name := dir + "/a.rpm"
+	mtime := time.Unix(1700000000, 0)
+
+	p := &testRPM{Name: "a", Version: "1.0", Release: "1", Arch: "noarch",
+		Changelog: []*testChangelog{
+			{Time: 300, Author: "C", Text: "- third"},
+			{Time: 200, Author: "B", Text: "- second"},
+			{Time: 100, Author: "A", Text: "- first"},
+		},
+	}
+
+	tests := []struct {
+		// Summary is written to the RPM, keeping its size and
+		// modification time, before creating the repository
+		Summary      string
+		ChecksumType string
+		Limit        int
+		Expect       string
+		Changelog    int
+	}{
+		{"aaa", "", 0, "aaa", 3},
+		{"bbb", "", 0, "aaa", 3},
+		{"bbb", "", 1, "bbb", 1},
+		{"ccc", "", 1, "bbb", 1},
+		{"ccc", "sha1", 1, "ccc", 1},
+		{"ddd", "sha1", 1, "ccc", 1},
+		{"ddd", "sha1", 0, "ddd", 3},
+	}
+
+	for i, test := range tests {
+		p.Summary = test.Summary
+		writeTestRPM(t, name, p)
+		if err := os.Chtimes(name, mtime, mtime); err != nil {
+			t.Fatal(err)
+		}
+
+		r, err := NewRepo(dir, &Config{Update: true, ChecksumCacheType: "none", ChecksumType: test.ChecksumType, ChangelogLimit: test.Limit})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := r.Create(); err != nil {
+			t.Fatal(err)
+		}
+
+		repo, err := OpenRepo(dir)
+		if err != nil {
+			t.Fatal(err)
+		}
+		ls := repo.PackagesByName("a")
+		if len(ls) != 1 {
+			t.Fatalf("test %d: got %d packages, expected 1", i, len(ls))
+		}
+		if ls[0].Summary != test.Expect || len(ls[0].Changelog) != test.Changelog {
+			t.Fatalf("test %d: got summary %q with %d changelog entries, expected %q with %d", i, ls[0].Summary, len(ls[0].Changelog), test.Expect, test.Changelog)
+		}
+	}
+}
+
+func TestUpdateLocationHref(t *testing.T) {
+	dir := t.TempDir()
+	name := dir + "/sub/a+b~1.rpm"
+	mtime := time.Unix(1700000000, 0)
+	if err := os.Mkdir(dir+"/sub", 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	for i, summary := range []string{"aaa", "bbb"} {
+		writeTestRPM(t, name, &testRPM{Name: "a", Version: "1.0", Release: "1", Arch: "noarch", Summary: summary})
+		if err := os.Chtimes(name, mtime, mtime); err != nil {
+			t.Fatal(err)
+		}
+
+		r, err := NewRepo(dir, &Config{Update: true, ChecksumCacheType: "none"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := r.Create(); err != nil {
+			t.Fatal(err)
+		}
+
+		repomd, err := r.readRepoMD()
+		if err != nil {
+			t.Fatal(err)
+		}
+		p := &primary{}
+		if err := decodeData(repomd.find("primary"), dir, p); err != nil {
+			t.Fatal(err)
+		}
+		if len(p.Packages) != 1 {
+			t.Fatalf("test %d: got %d packages, expected 1", i, len(p.Packages))
+		}
+		if got := p.Packages[0].Location.Href; got != "sub/a%2Bb~1.rpm" {
+			t.Fatalf("test %d: got href %q, expected sub/a%%2Bb~1.rpm", i, got)
+		}
+		if got := p.Packages[0].Summary; got != "aaa" {
+			t.Fatalf("test %d: got summary %q, expected the reused aaa", i, got)
+		}
+	}
+}
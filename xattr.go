@@ -1,33 +1,68 @@
 package createrepo
 
 import (
-	"fmt"
+	"encoding/hex"
 	"github.com/pkg/xattr"
-	"strings"
+	"io/fs"
 )
 
-func getXattrChecksum(name string) (*checksum, bool) {
-	data, err := xattr.Get(name, "user.repo.checksum")
-	if err != nil {
-		return nil, false
-	}
-	fields := strings.Fields(string(data))
-	if len(fields) != 2 {
-		return nil, false
+// xattrChecksumCache is a ChecksumCache stored in user extended
+// attributes of the RPMs.
+type xattrChecksumCache struct {
+	fallback ChecksumCache
+}
+
+// NewXattrChecksumCache returns a ChecksumCache storing checksums in
+// the user.repo.checksum.<type> extended attribute of each RPM. If an
+// attribute can't be written, e.g. on file systems without support for
+// user extended attributes or on read-only file systems, the checksum
+// is cached in fallback instead. Fallback may be nil.
+func NewXattrChecksumCache(fallback ChecksumCache) ChecksumCache {
+	if fallback == nil {
+		fallback = NewNoChecksumCache()
 	}
 
-	switch fields[0] {
-	case "sha256":
-		return &checksum{Type: "sha256", PkgID: "YES", Data: fields[1]}, true
+	return &xattrChecksumCache{fallback: fallback}
+}
+
+// xattrChecksumName returns the name of the extended attribute
+// caching the checksum of the given type.
+func xattrChecksumName(checksumType string) string {
+	return "user.repo.checksum." + checksumType
+}
+
+func (c *xattrChecksumCache) Get(name string, fi fs.FileInfo, checksumType string) (string, bool) {
+	if data, err := xattr.Get(name, xattrChecksumName(checksumType)); err == nil {
+		if e, ok := parseCacheEntry(string(data)); ok && e.valid(fi, checksumType) {
+			return e.Digest, true
+		}
 	}
 
-	return nil, false
+	return c.fallback.Get(name, fi, checksumType)
 }
 
-func setXattrChecksum(name string, checksum *checksum) error {
-	if err := xattr.Set(name, "user.repo.checksum", []byte(fmt.Sprintf("%s %s", checksum.Type, checksum.Data))); err != nil {
-		return err
+func (c *xattrChecksumCache) Set(name string, fi fs.FileInfo, checksumType, digest string) error {
+	e := newCacheEntry(fi, digest)
+	if err := xattr.Set(name, xattrChecksumName(checksumType), []byte(e.String())); err != nil {
+		return c.fallback.Set(name, fi, checksumType, digest)
 	}
 
 	return nil
 }
+
+func (c *xattrChecksumCache) Flush() error {
+	return c.fallback.Flush()
+}
+
+// validDigest returns true if digest is a hex encoded digest of the
+// checksum type.
+func validDigest(checksumType, digest string) bool {
+	h, err := newHash(checksumType)
+	if err != nil {
+		return false
+	}
+
+	b, err := hex.DecodeString(digest)
+
+	return err == nil && len(b) == h.Size()
+}
@@ -0,0 +1,195 @@
+package createrepo
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha256"
+	"github.com/klauspost/compress/zstd"
+	"hash"
+	"io"
+	"os"
+)
+
+const (
+	// zckHashSHA256 is the zchunk checksum type for SHA-256
+	zckHashSHA256 = 1
+
+	// zckCompZstd is the zchunk compression type for zstd
+	zckCompZstd = 2
+)
+
+// chunkWriter is a writer which content can be split into chunks.
+type chunkWriter interface {
+	io.Writer
+	endChunk() error
+}
+
+// zckChunk represents a single chunk in the zchunk index.
+type zckChunk struct {
+	checksum []byte
+	size     uint64
+	openSize uint64
+}
+
+// zckWriter writes a zchunk file. Each chunk is compressed as an
+// independent zstd frame, so clients only need to download the chunks
+// that changed. The header holds the checksums of all chunks, and the
+// compressed chunks are therefore kept in a temporary file until
+// Close.
+type zckWriter struct {
+	out            io.Writer
+	data           *os.File
+	dataBuf        *bufio.Writer
+	dataHash       hash.Hash
+	enc            *zstd.Encoder
+	chunk          []byte
+	chunks         []*zckChunk
+	headerChecksum []byte
+	headerSize     uint64
+}
+
+// newZckWriter returns a zchunk writer writing to out upon Close. The
+// compressed chunks are kept in a temporary file in dir.
+func newZckWriter(out io.Writer, dir string, compressLevel int) (*zckWriter, error) {
+	var opts []zstd.EOption
+	if compressLevel > 0 {
+		opts = append(opts, zstd.WithEncoderLevel(zstd.EncoderLevelFromZstd(compressLevel)))
+	}
+	enc, err := zstd.NewWriter(nil, opts...)
+	if err != nil {
+		return nil, err
+	}
+
+	data, err := os.CreateTemp(dir, "zck-*.tmp")
+	if err != nil {
+		return nil, err
+	}
+
+	return &zckWriter{
+		out:      out,
+		data:     data,
+		dataBuf:  bufio.NewWriter(data),
+		dataHash: sha256.New(),
+		enc:      enc,
+	}, nil
+}
+
+func (z *zckWriter) Write(p []byte) (int, error) {
+	z.chunk = append(z.chunk, p...)
+	return len(p), nil
+}
+
+// endChunk compresses the content written since the last chunk as a
+// new chunk.
+func (z *zckWriter) endChunk() error {
+	if len(z.chunk) == 0 {
+		return nil
+	}
+
+	compressed := z.enc.EncodeAll(z.chunk, nil)
+	sum := sha256.Sum256(compressed)
+
+	if _, err := z.dataBuf.Write(compressed); err != nil {
+		return err
+	}
+	z.dataHash.Write(compressed)
+
+	z.chunks = append(z.chunks, &zckChunk{
+		checksum: sum[:],
+		size:     uint64(len(compressed)),
+		openSize: uint64(len(z.chunk)),
+	})
+	z.chunk = z.chunk[:0]
+
+	return nil
+}
+
+// Close ends the last chunk and writes the header followed by the
+// compressed chunks.
+func (z *zckWriter) Close() error {
+	defer z.discard()
+
+	if err := z.endChunk(); err != nil {
+		return err
+	}
+
+	if err := z.dataBuf.Flush(); err != nil {
+		return err
+	}
+
+	header := z.header()
+	if _, err := z.out.Write(header); err != nil {
+		return err
+	}
+
+	if _, err := z.data.Seek(0, io.SeekStart); err != nil {
+		return err
+	}
+
+	_, err := io.Copy(z.out, z.data)
+
+	return err
+}
+
+// discard removes the temporary file holding the compressed chunks.
+func (z *zckWriter) discard() {
+	z.data.Close()
+	os.Remove(z.data.Name())
+}
+
+// header returns the zchunk header: the lead, preface, index and
+// signatures. The header checksum in the lead covers all of the
+// header except the checksum itself.
+func (z *zckWriter) header() []byte {
+	var preface []byte
+	preface = append(preface, z.dataHash.Sum(nil)...)
+	preface = appendCompInt(preface, 0) // flags
+	preface = appendCompInt(preface, zckCompZstd)
+
+	var index []byte
+	index = appendCompInt(index, zckHashSHA256)
+	index = appendCompInt(index, uint64(len(z.chunks)+1))
+
+	// The first chunk is the dictionary, which is empty
+	index = append(index, make([]byte, sha256.Size)...)
+	index = appendCompInt(index, 0)
+	index = appendCompInt(index, 0)
+
+	for _, c := range z.chunks {
+		index = append(index, c.checksum...)
+		index = appendCompInt(index, c.size)
+		index = appendCompInt(index, c.openSize)
+	}
+	index = append(appendCompInt(nil, uint64(len(index))), index...)
+
+	signatures := appendCompInt(nil, 0)
+
+	lead := []byte("\x00ZCK1")
+	lead = appendCompInt(lead, zckHashSHA256)
+	lead = appendCompInt(lead, uint64(len(preface)+len(index)+len(signatures)))
+
+	h := sha256.New()
+	h.Write(lead)
+	h.Write(preface)
+	h.Write(index)
+	h.Write(signatures)
+	z.headerChecksum = h.Sum(nil)
+
+	header := bytes.Join([][]byte{lead, z.headerChecksum, preface, index, signatures}, nil)
+	z.headerSize = uint64(len(header))
+
+	return header
+}
+
+// appendCompInt appends v as a zchunk compressed integer: little
+// endian base 128, with the high bit set in the last byte only.
+func appendCompInt(b []byte, v uint64) []byte {
+	for {
+		c := byte(v % 128)
+		v /= 128
+		if v == 0 {
+			return append(b, c|0x80)
+		}
+		b = append(b, c)
+	}
+}
@@ -0,0 +1,259 @@
+package createrepo
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/xml"
+	"fmt"
+	"github.com/klauspost/compress/zstd"
+	"strings"
+	"testing"
+)
+
+func TestAppendCompInt(t *testing.T) {
+	tests := []struct {
+		Value  uint64
+		Expect []byte
+	}{
+		{0, []byte{0x80}},
+		{1, []byte{0x81}},
+		{127, []byte{0xff}},
+		{128, []byte{0x00, 0x81}},
+		{300, []byte{0x2c, 0x82}},
+		{16383, []byte{0x7f, 0xff}},
+		{16384, []byte{0x00, 0x00, 0x81}},
+	}
+
+	for _, test := range tests {
+		if got := appendCompInt(nil, test.Value); !bytes.Equal(got, test.Expect) {
+			t.Fatalf("appendCompInt(%d): got %x, expected %x", test.Value, got, test.Expect)
+		}
+	}
+}
+
+// zckReader decodes a zchunk file following the format specification,
+// independently of zckWriter.
+type zckReader struct {
+	b   []byte
+	pos int
+}
+
+func (r *zckReader) bytes(n int) []byte {
+	if r.pos+n > len(r.b) {
+		panic(fmt.Sprintf("read of %d bytes at %d exceeds %d", n, r.pos, len(r.b)))
+	}
+	ret := r.b[r.pos : r.pos+n]
+	r.pos += n
+	return ret
+}
+
+func (r *zckReader) compInt() uint64 {
+	var v uint64
+	for shift := 0; ; shift += 7 {
+		c := r.bytes(1)[0]
+		v |= uint64(c&0x7f) << shift
+		if c&0x80 != 0 {
+			return v
+		}
+	}
+}
+
+// zckIndexEntry represents a chunk in the index of a zchunk file.
+type zckIndexEntry struct {
+	checksum []byte
+	size     uint64
+	openSize uint64
+}
+
+func TestZckWriter(t *testing.T) {
+	chunks := []string{"<?xml?>\n<root>", "<a>first</a>", "<b>second</b>", "</root>\n"}
+
+	var buf bytes.Buffer
+	z, err := newZckWriter(&buf, t.TempDir(), 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, c := range chunks {
+		if _, err := z.Write([]byte(c)); err != nil {
+			t.Fatal(err)
+		}
+		if i < len(chunks)-1 {
+			if err := z.endChunk(); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+	if err := z.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	r := &zckReader{b: buf.Bytes()}
+
+	// Lead
+	if id := r.bytes(5); string(id) != "\x00ZCK1" {
+		t.Fatalf("got id %q, expected \\0ZCK1", id)
+	}
+	if v := r.compInt(); v != 1 {
+		t.Fatalf("got header checksum type %d, expected 1 (sha256)", v)
+	}
+	headerSize := r.compInt()
+	leadEnd := r.pos
+	headerChecksum := r.bytes(sha256.Size)
+	headerEnd := r.pos + int(headerSize)
+
+	h := sha256.New()
+	h.Write(r.b[:leadEnd])
+	h.Write(r.b[r.pos:headerEnd])
+	if !bytes.Equal(h.Sum(nil), headerChecksum) {
+		t.Fatalf("header checksum mismatch")
+	}
+	if !bytes.Equal(z.headerChecksum, headerChecksum) || z.headerSize != uint64(headerEnd) {
+		t.Fatalf("got header checksum %x and size %d, expected %x and %d", z.headerChecksum, z.headerSize, headerChecksum, headerEnd)
+	}
+
+	// Preface
+	dataChecksum := r.bytes(sha256.Size)
+	if v := r.compInt(); v != 0 {
+		t.Fatalf("got flags %d, expected 0", v)
+	}
+	if v := r.compInt(); v != 2 {
+		t.Fatalf("got compression type %d, expected 2 (zstd)", v)
+	}
+
+	// Index
+	indexSize := r.compInt()
+	indexEnd := r.pos + int(indexSize)
+	if v := r.compInt(); v != 1 {
+		t.Fatalf("got chunk checksum type %d, expected 1 (sha256)", v)
+	}
+	count := r.compInt()
+	if count != uint64(len(chunks)+1) {
+		t.Fatalf("got %d chunks, expected %d including the dictionary", count, len(chunks)+1)
+	}
+	var index []*zckIndexEntry
+	for i := uint64(0); i < count; i++ {
+		index = append(index, &zckIndexEntry{checksum: r.bytes(sha256.Size), size: r.compInt(), openSize: r.compInt()})
+	}
+	if r.pos != indexEnd {
+		t.Fatalf("index ends at %d, expected %d", r.pos, indexEnd)
+	}
+
+	// Signatures
+	if v := r.compInt(); v != 0 {
+		t.Fatalf("got %d signatures, expected 0", v)
+	}
+	if r.pos != headerEnd {
+		t.Fatalf("header ends at %d, expected %d", r.pos, headerEnd)
+	}
+
+	// The empty dictionary
+	if !bytes.Equal(index[0].checksum, make([]byte, sha256.Size)) || index[0].size != 0 || index[0].openSize != 0 {
+		t.Fatalf("got dictionary %x %d %d, expected an empty one", index[0].checksum, index[0].size, index[0].openSize)
+	}
+
+	if sum := sha256.Sum256(r.b[headerEnd:]); !bytes.Equal(sum[:], dataChecksum) {
+		t.Fatalf("data checksum mismatch")
+	}
+
+	dec, err := zstd.NewReader(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer dec.Close()
+
+	offset := uint64(headerEnd)
+	for i, c := range index[1:] {
+		compressed := r.b[offset : offset+c.size]
+		if sum := sha256.Sum256(compressed); !bytes.Equal(sum[:], c.checksum) {
+			t.Fatalf("chunk %d at offset %d: checksum mismatch", i, offset)
+		}
+		content, err := dec.DecodeAll(compressed, nil)
+		if err != nil {
+			t.Fatalf("chunk %d at offset %d: %v", i, offset, err)
+		}
+		if string(content) != chunks[i] || uint64(len(content)) != c.openSize {
+			t.Fatalf("chunk %d at offset %d: got %q (%d), expected %q (%d)", i, offset, content, len(content), chunks[i], c.openSize)
+		}
+		offset += c.size
+	}
+	if offset != uint64(len(r.b)) {
+		t.Fatalf("chunks end at %d, expected %d", offset, len(r.b))
+	}
+}
+
+func TestZckWriterEmpty(t *testing.T) {
+	var buf bytes.Buffer
+	z, err := newZckWriter(&buf, t.TempDir(), 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := z.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	empty := sha256.Sum256(nil)
+	expect := []byte("\x00ZCK1\x81\xc8")
+	expect = append(expect, z.headerChecksum...)
+	expect = append(expect, empty[:]...)
+	expect = append(expect, 0x80, 0x82, 0xa4, 0x81, 0x81)
+	expect = append(expect, make([]byte, sha256.Size)...)
+	expect = append(expect, 0x80, 0x80, 0x80)
+
+	if !bytes.Equal(buf.Bytes(), expect) {
+		t.Fatalf("got %x, expected %x", buf.Bytes(), expect)
+	}
+}
+
+// chunkRecorder records the chunks written to it.
+type chunkRecorder struct {
+	chunks []string
+	chunk  strings.Builder
+}
+
+func (c *chunkRecorder) Write(p []byte) (int, error) {
+	return c.chunk.Write(p)
+}
+
+func (c *chunkRecorder) endChunk() error {
+	c.chunks = append(c.chunks, c.chunk.String())
+	c.chunk.Reset()
+	return nil
+}
+
+func TestEncodeXMLChunks(t *testing.T) {
+	p := &primary{
+		Namespace:    "http://linux.duke.edu/metadata/common",
+		NamespaceRPM: "http://linux.duke.edu/metadata/rpm",
+		Count:        "2",
+		Packages: []*rpmPackage{
+			{Type: "rpm", Name: "a", Arch: "noarch"},
+			{Type: "rpm", Name: "b", Arch: "noarch"},
+		},
+	}
+
+	w := &chunkRecorder{}
+	if err := p.encodeChunks(w); err != nil {
+		t.Fatal(err)
+	}
+	w.endChunk()
+
+	if len(w.chunks) != 3 {
+		t.Fatalf("got %d chunks, expected 3", len(w.chunks))
+	}
+	if !strings.HasSuffix(w.chunks[0], "<metadata xmlns=\"http://linux.duke.edu/metadata/common\" xmlns:rpm=\"http://linux.duke.edu/metadata/rpm\" packages=\"2\">") ||
+		!strings.Contains(w.chunks[1], "<name>a</name>") || !strings.Contains(w.chunks[2], "<name>b</name>") {
+		t.Fatalf("unexpected chunks: %q", w.chunks)
+	}
+
+	doc := strings.Join(w.chunks, "")
+	if err := xml.Unmarshal([]byte(doc), &primary{}); err != nil {
+		t.Fatalf("chunks are not a valid document: %v", err)
+	}
+	b, err := p.XML()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if doc != string(b) {
+		t.Fatalf("got %q, expected the same as XML: %q", doc, b)
+	}
+}